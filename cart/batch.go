@@ -0,0 +1,136 @@
+package cart
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// A basket to be priced by PriceBatch.
+type PricingRequest struct {
+	ID         string // Identifies the basket in its result. Ie. A saved cart ID.
+	Items      ProductCollectionType
+	PromoCodes []string
+	Context    PricingContext
+}
+
+type BatchResult struct {
+	ID     string
+	Index  int // Position of the request in the order requests were received.
+	Result PricingResult
+}
+
+type BatchOptions struct {
+	Workers int // Baskets priced at once. Zero uses one per CPU.
+	Buffer  int // Results which may be waiting to be received before workers block.
+}
+
+// Prices the baskets received from requests in parallel, streaming a result
+// for each. Results arrive in the order baskets finish pricing, not the order
+// they were requested.
+//
+// The catalogue and rules are shared between workers, so must not be modified
+// until the results channel is closed. Rules must not keep state between
+// evaluations. The built in rules don't.
+//
+// The results channel is closed once requests is closed and every basket has
+// been priced, or once ctx is done. In the latter case some baskets are not
+// priced, which is reported by ctx.Err(), and requests is no longer received
+// from.
+func PriceBatch(ctx context.Context, catalogue Catalogue, rules []Rule, requests <-chan PricingRequest, opts BatchOptions) <-chan BatchResult {
+	results := make(chan BatchResult, opts.Buffer)
+	traits := ruleTraitsOf(rules)
+
+	priceInParallel(ctx, requests, opts.Workers, func() func(indexedRequest) bool {
+		e := createBatchEngine(catalogue, rules, traits)
+		return func(req indexedRequest) bool {
+			select {
+			case results <- BatchResult{req.ID, req.index, e.priceBasket(req.Items, req.PromoCodes, req.Context)}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+	}, func() {
+		close(results)
+	})
+
+	return results
+}
+
+// Numbers the requests as received and hands them to workers, one per CPU if
+// workers is zero. Each worker prices requests with the function newWorker
+// returns, so engines are private to the worker, until it returns false or
+// ctx is done. Calls done once every worker has stopped.
+func priceInParallel(ctx context.Context, requests <-chan PricingRequest, workers int, newWorker func() func(indexedRequest) bool, done func()) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	queue := make(chan indexedRequest)
+	go numberRequests(ctx, requests, queue)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			work(ctx, queue, newWorker())
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		done()
+	}()
+}
+
+// Creates an engine for one worker. Only the outcome of each rule is private
+// to the worker.
+func createBatchEngine(catalogue Catalogue, rules []Rule, traits []ruleTraits) *pricingEngine {
+	return &pricingEngine{
+		catalogue: catalogue,
+		rules:     rules,
+		traits:    traits,
+		results:   make([]ruleResult, len(rules)),
+	}
+}
+
+type indexedRequest struct {
+	PricingRequest
+	index int
+}
+
+func numberRequests(ctx context.Context, requests <-chan PricingRequest, queue chan<- indexedRequest) {
+	defer close(queue)
+
+	for i := 0; ; i++ {
+		select {
+		case <-ctx.Done():
+			return
+		case req, ok := <-requests:
+			if !ok {
+				return
+			}
+
+			select {
+			case queue <- indexedRequest{req, i}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func work(ctx context.Context, queue <-chan indexedRequest, price func(indexedRequest) bool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req, ok := <-queue:
+			if !ok || !price(req) {
+				return
+			}
+		}
+	}
+}
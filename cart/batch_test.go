@@ -0,0 +1,93 @@
+package cart
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func createBatchTestRequests(catalogue Catalogue, n int) []PricingRequest {
+	codes := []string{"ult_small", "ult_medium", "ult_large", "1gb"}
+	requests := make([]PricingRequest, n)
+	for i := range requests {
+		var basket []string
+		for j := 0; j <= i%7; j++ {
+			basket = append(basket, codes[(i+j)%len(codes)])
+		}
+
+		items, _ := CreateItems(catalogue, basket...)
+		requests[i] = PricingRequest{ID: fmt.Sprintf("cart-%d", i), Items: items}
+		if i%3 == 0 {
+			requests[i].PromoCodes = []string{"I<3AMAYSIM"}
+		}
+	}
+
+	return requests
+}
+
+func sendRequests(requests []PricingRequest) <-chan PricingRequest {
+	ch := make(chan PricingRequest)
+	go func() {
+		defer close(ch)
+		for _, req := range requests {
+			ch <- req
+		}
+	}()
+
+	return ch
+}
+
+func Test_PriceBatch_WHEN_ManyBaskets_EXPECT_SameAsPrice(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rules := createIndexTestRules()
+	requests := createBatchTestRequests(catalogue, 500)
+
+	seen := make(map[int]bool)
+	for r := range PriceBatch(context.Background(), catalogue, rules, sendRequests(requests), BatchOptions{Workers: 4}) {
+		req := requests[r.Index]
+		if r.ID != req.ID || seen[r.Index] {
+			t.Fatalf("ID=%s Index=%d, Expected=%s once", r.ID, r.Index, req.ID)
+		}
+		seen[r.Index] = true
+
+		expected := Price(catalogue, rules, req.Items, req.PromoCodes, req.Context)
+		if r.Result.Total != expected.Total || len(r.Result.AppliedRules) != len(expected.AppliedRules) {
+			t.Errorf("%s: Total=%d AppliedRules=%v, Expected=%d %v", r.ID, r.Result.Total, r.Result.AppliedRules, expected.Total, expected.AppliedRules)
+		}
+	}
+
+	if len(seen) != len(requests) {
+		t.Errorf("Results=%d, Expected=%d", len(seen), len(requests))
+	}
+}
+
+func Test_PriceBatch_WHEN_Cancelled_EXPECT_ResultsClosed(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Never closed, so only cancellation ends the batch.
+	requests := make(chan PricingRequest)
+	go func() {
+		for _, req := range createBatchTestRequests(catalogue, 1000) {
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := PriceBatch(ctx, catalogue, CreateDefaultRules(), requests, BatchOptions{Workers: 2})
+	n := 0
+	for range results {
+		n++
+		if n == 10 {
+			cancel()
+		}
+	}
+
+	if n < 10 || n >= 1000 {
+		t.Errorf("Results=%d, Expected some but not all", n)
+	}
+}
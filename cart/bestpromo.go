@@ -0,0 +1,190 @@
+package cart
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The most codes a cart which selects the best promo codes may hold, as every
+// combination of exclusive codes is priced.
+const maxBestPromoCodes = 8
+
+// A promo code held by a cart but not applied, as it cannot be combined with
+// codes which together give a lower total.
+type SetAsidePromo struct {
+	Code       string
+	InFavourOf []string  // The applied codes it cannot be combined with. Empty if the total is lower without it.
+	Total      PriceType // The lowest total of the cart with the code applied.
+	BestTotal  PriceType // The total of the cart with the codes applied instead.
+}
+
+func (sa SetAsidePromo) String() string {
+	if len(sa.InFavourOf) == 0 {
+		return fmt.Sprintf("set aside as the total is lower without it (%d rather than %d)", sa.BestTotal, sa.Total)
+	}
+
+	return fmt.Sprintf("set aside for %s, which give a lower total (%d rather than %d)", strings.Join(sa.InFavourOf, ", "), sa.BestTotal, sa.Total)
+}
+
+// Returns the promo codes set aside in favour of exclusive codes which give a
+// lower total, sorted by code. Only a cart whose PromoPolicy has SelectBest
+// set sets codes aside.
+func (c *defaultCart) SetAsidePromoCodes() []SetAsidePromo {
+	codes := make([]string, 0, len(c.setAside))
+	for code := range c.setAside {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	setAside := make([]SetAsidePromo, len(codes))
+	for i, code := range codes {
+		setAside[i] = c.setAside[code]
+	}
+
+	return setAside
+}
+
+// Returns whether two normalized codes are in an exclusive group.
+func (p PromoPolicy) excludes(a, b string) bool {
+	if a == b {
+		return false
+	}
+
+	_, ok := p.exclusiveWith(a, map[string]bool{b: true})
+	return ok
+}
+
+// Chooses which of the exclusive promo codes held by the cart to apply, by
+// pricing the cart with each combination of them which the policy permits,
+// including those leaving out codes which could be combined, as a code may
+// stop another rule applying. The combination giving the lowest total is
+// applied, keeping the most codes applied now on a tie, then the first
+// combination in code order.
+func (c *defaultCart) selectBestPromoCodes() {
+	var fixed, conflicting []string
+	for _, code := range sortedCodes(c.codesHeld()) {
+		exclusive := false
+		for other := range c.promoCodes {
+			exclusive = exclusive || c.promoPolicy.excludes(code, other)
+		}
+
+		if exclusive {
+			conflicting = append(conflicting, code)
+		} else {
+			fixed = append(fixed, code)
+		}
+	}
+
+	setAside := make(map[string]SetAsidePromo)
+	if len(conflicting) > 0 {
+		e := createBatchEngine(c.catalogue, c.rules, c.engine.traits)
+		ctx := PricingContext{Customer: c.customer, GiftSelections: c.giftSelections, BundleChoices: c.bundleChoices, NormalizePromo: c.promoPolicy.Normalize}
+		now := c.now()
+
+		var best []string
+		var bestTotal PriceType
+		found := false
+		lowest := make(map[string]PriceType) // Code => lowest total with it applied.
+		for _, combination := range c.promoPolicy.combinations(conflicting) {
+			total := e.priceWith(c.products, append(append([]string(nil), fixed...), combination...), ctx, func(i int, rule Rule) bool {
+				return c.ruleAvailable(i, rule, now)
+			}).Total
+
+			for _, code := range combination {
+				if t, ok := lowest[code]; !ok || total < t {
+					lowest[code] = total
+				}
+			}
+
+			if !found || total < bestTotal || (total == bestTotal && c.countAppliedNow(combination) > c.countAppliedNow(best)) {
+				best, bestTotal, found = combination, total, true
+			}
+		}
+
+		for _, code := range conflicting {
+			if contains(best, code) {
+				continue
+			}
+
+			var inFavourOf []string
+			for _, b := range best {
+				if c.promoPolicy.excludes(code, b) {
+					inFavourOf = append(inFavourOf, b)
+				}
+			}
+			setAside[code] = SetAsidePromo{code, inFavourOf, lowest[code], bestTotal}
+		}
+	}
+
+	// The rules see the codes applied, so those which change must be
+	// re-evaluated.
+	for _, code := range sortedCodes(c.codesHeld()) {
+		_, before := c.setAside[code]
+		sa, after := setAside[code]
+		if before == after {
+			continue
+		}
+
+		c.promoChanged(code)
+		if after {
+			c.promoEvents = append(c.promoEvents, Event{Type: PromoSetAside, PromoCode: code, SetAside: sa})
+		} else {
+			c.promoEvents = append(c.promoEvents, Event{Type: PromoApplied, PromoCode: code})
+		}
+	}
+	c.setAside = setAside
+}
+
+// Returns the promo codes entered, whether applied or set aside.
+func (c *defaultCart) codesHeld() []string {
+	codes := make([]string, 0, len(c.promoCodes))
+	for code := range c.promoCodes {
+		codes = append(codes, code)
+	}
+
+	return codes
+}
+
+// Returns how many of the codes were applied when rules were last evaluated.
+// Codes entered since were not.
+func (c *defaultCart) countAppliedNow(codes []string) int {
+	n := 0
+	for _, code := range codes {
+		if _, ok := c.setAside[code]; !ok && !c.changedPromoCodes[code] {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Returns every combination of the codes which the policy permits, including
+// the empty one, in code order.
+func (p PromoPolicy) combinations(codes []string) [][]string {
+	var combinations [][]string
+	var chosen []string
+
+	var choose func(i int)
+	choose = func(i int) {
+		if i == len(codes) {
+			combinations = append(combinations, append([]string(nil), chosen...))
+			return
+		}
+
+		permitted := true
+		for _, code := range chosen {
+			permitted = permitted && !p.excludes(codes[i], code)
+		}
+
+		if permitted {
+			chosen = append(chosen, codes[i])
+			choose(i + 1)
+			chosen = chosen[:len(chosen)-1]
+		}
+		choose(i + 1)
+	}
+	choose(0)
+
+	return combinations
+}
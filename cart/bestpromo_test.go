@@ -0,0 +1,176 @@
+package cart
+
+import (
+	"reflect"
+	"testing"
+)
+
+func createBestPromoTestCart(opts ...CartOption) Cart {
+	rules := []Rule{
+		CreatePromoRule("I<3AMAYSIM", 10),
+		CreateConditionalRule(HasPromoCode("TENOFF"), FixedDiscount(1000)),
+		CreatePromoRule("SIMS", 5),
+	}
+
+	policy := CreateDefaultPromoPolicy()
+	policy.ExclusiveGroups = [][]string{{"I<3AMAYSIM", "TENOFF"}}
+	policy.SelectBest = true
+
+	return CreateCart(rules, CreateDefaultCatalogue(), append(opts, WithPromoPolicy(policy))...)
+}
+
+func Test_BestPromo_WHEN_ExclusiveCodes_EXPECT_LowestTotalApplied(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+
+	for _, opts := range [][]CartOption{nil, {WithFullEvaluation()}} {
+		c := createBestPromoTestCart(opts...)
+		c.Add(catalogue["ult_medium"])
+
+		if err := c.AddPromoCode("i<3amaysim"); err != nil {
+			t.Fatalf("Error=%v", err)
+		}
+		if err := c.AddPromoCode("tenoff"); err != nil {
+			t.Fatalf("Error=%v", err)
+		}
+
+		// $10 off beats 10% off $29.90.
+		if c.Total() != 1990 || !reflect.DeepEqual(c.PromoCodes(), []string{"TENOFF"}) {
+			t.Errorf("CartTotal=%d PromoCodes=%v, Expected=1990 [TENOFF]", c.Total(), c.PromoCodes())
+		}
+
+		expected := []SetAsidePromo{{"I<3AMAYSIM", []string{"TENOFF"}, 2691, 1990}}
+		if setAside := c.SetAsidePromoCodes(); !reflect.DeepEqual(setAside, expected) {
+			t.Errorf("SetAsidePromoCodes=%v, Expected=%v", setAside, expected)
+		}
+
+		// 10% off beats $10 off once the cart is over $100.
+		for i := 0; i < 4; i++ {
+			c.Add(catalogue["ult_large"])
+		}
+
+		if expected := PriceType(20950 - 2095); c.Total() != expected || !reflect.DeepEqual(c.PromoCodes(), []string{"I<3AMAYSIM"}) {
+			t.Errorf("CartTotal=%d PromoCodes=%v, Expected=%d [I<3AMAYSIM]", c.Total(), c.PromoCodes(), expected)
+		}
+
+		// Codes which can be combined are always applied.
+		c.AddPromoCode("SIMS")
+
+		if expected := PriceType(20950 - 2095 - 1047); c.Total() != expected || len(c.PromoCodes()) != 2 {
+			t.Errorf("CartTotal=%d PromoCodes=%v, Expected=%d", c.Total(), c.PromoCodes(), expected)
+		}
+
+		if setAside := c.SetAsidePromoCodes(); len(setAside) != 1 || setAside[0].Code != "TENOFF" {
+			t.Errorf("SetAsidePromoCodes=%v", setAside)
+		}
+	}
+}
+
+func Test_BestPromo_WHEN_SetAside_EXPECT_DiagnosedAsExcluded(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := createBestPromoTestCart()
+	c.Add(catalogue["ult_medium"])
+	c.AddPromoCode("I<3AMAYSIM")
+	c.AddPromoCode("TENOFF")
+
+	d := diagnosePromo(t, c, "I<3AMAYSIM")
+	if d.Status != PromoExcluded || d.Reasons[0].Detail != "set aside for TENOFF, which give a lower total (1990 rather than 2691)" {
+		t.Errorf("PromoDiagnosis=%v", d)
+	}
+
+	if d := diagnosePromo(t, c, "TENOFF"); d.Status != PromoApplies {
+		t.Errorf("PromoDiagnosis=%v", d)
+	}
+
+	// Removing the code applied applies the code set aside.
+	c.RemovePromoCode("TENOFF")
+
+	if c.Total() != 2691 || len(c.SetAsidePromoCodes()) != 0 {
+		t.Errorf("CartTotal=%d SetAsidePromoCodes=%v", c.Total(), c.SetAsidePromoCodes())
+	}
+}
+
+func Test_BestPromo_WHEN_Tie_EXPECT_CodeAppliedKept(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	policy := PromoPolicy{ExclusiveGroups: [][]string{{"A", "B"}}, SelectBest: true}
+	c := CreateCart([]Rule{CreatePromoRule("A", 10), CreatePromoRule("B", 10)}, catalogue, WithPromoPolicy(policy))
+	c.Add(catalogue["ult_medium"])
+	c.AddPromoCode("B")
+	c.AddPromoCode("A")
+
+	if !reflect.DeepEqual(c.PromoCodes(), []string{"B"}) {
+		t.Errorf("PromoCodes=%v, Expected=[B]", c.PromoCodes())
+	}
+}
+
+func Test_BestPromo_WHEN_CodeStopsAnotherApplying_EXPECT_SetAsideThoughCombinable(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rules := []Rule{
+		CreateConditionalRule(And(HasPromoCode("A"), Not(HasPromoCode("C"))), FixedDiscount(1000)),
+		CreatePromoRule("B", 5),
+		CreateConditionalRule(HasPromoCode("C"), FixedDiscount(100)),
+	}
+	policy := PromoPolicy{ExclusiveGroups: [][]string{{"A", "B"}, {"B", "C"}}, SelectBest: true}
+	c := CreateCart(rules, catalogue, WithPromoPolicy(policy))
+	c.Add(catalogue["ult_medium"])
+	for _, code := range []string{"A", "B", "C"} {
+		c.AddPromoCode(code)
+	}
+
+	// A alone beats both A with C, and B.
+	if c.Total() != 1990 || !reflect.DeepEqual(c.PromoCodes(), []string{"A"}) {
+		t.Errorf("CartTotal=%d PromoCodes=%v, Expected=1990 [A]", c.Total(), c.PromoCodes())
+	}
+
+	expected := []SetAsidePromo{{"B", []string{"A"}, 2841, 1990}, {"C", nil, 2890, 1990}}
+	if setAside := c.SetAsidePromoCodes(); !reflect.DeepEqual(setAside, expected) {
+		t.Errorf("SetAsidePromoCodes=%v, Expected=%v", setAside, expected)
+	}
+
+	if d := diagnosePromo(t, c, "C"); d.Status != PromoExcluded || d.Reasons[0].Detail != "set aside as the total is lower without it (1990 rather than 2890)" {
+		t.Errorf("PromoDiagnosis=%v", d)
+	}
+}
+
+func Test_BestPromo_WHEN_ManyCodes_EXPECT_Capped(t *testing.T) {
+	c := CreateCart(nil, CreateDefaultCatalogue(), WithPromoPolicy(PromoPolicy{SelectBest: true}))
+	for _, code := range []string{"A", "B", "C", "D", "E", "F", "G", "H"} {
+		c.AddPromoCode(code)
+	}
+
+	if err := c.AddPromoCode("I"); err == nil || err.(*PromoError).Limit != maxBestPromoCodes {
+		t.Errorf("Error=%v", err)
+	}
+}
+
+func Test_BestPromo_WHEN_CodeSetAside_EXPECT_SetAsideEventNotApplied(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	recorder := &eventRecorder{}
+	c := createBestPromoTestCart(WithListener(recorder))
+	c.Add(catalogue["ult_medium"])
+	c.AddPromoCode("TENOFF")
+	recorder.reset()
+
+	c.AddPromoCode("I<3AMAYSIM")
+
+	if !reflect.DeepEqual(recorder.types(), []EventType{PromoSetAside}) {
+		t.Fatalf("Events=%v, Expected=[PromoSetAside]", recorder.types())
+	}
+
+	if e := recorder.events[0]; e.PromoCode != "I<3AMAYSIM" || e.SetAside.Total != 2691 || !reflect.DeepEqual(e.SetAside.InFavourOf, []string{"TENOFF"}) {
+		t.Errorf("Event=%v", e)
+	}
+
+	// Once 10% off is worth more the codes swap.
+	c.Add(catalogue["ult_large"])
+	recorder.reset()
+	c.Add(catalogue["ult_large"])
+
+	expected := []EventType{ItemAdded, PromoApplied, PromoSetAside, RuleReverted, RuleTriggered}
+	if !reflect.DeepEqual(recorder.types(), expected) {
+		t.Fatalf("Events=%v, Expected=%v", recorder.types(), expected)
+	}
+
+	if recorder.events[1].PromoCode != "I<3AMAYSIM" || recorder.events[2].PromoCode != "TENOFF" {
+		t.Errorf("Events=%v", recorder.events)
+	}
+}
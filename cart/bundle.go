@@ -0,0 +1,313 @@
+package cart
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	ErrUnknownBundle   = errors.New("cart: no optional bundle of that product is offered")
+	ErrMandatoryBundle = errors.New("cart: bundled product cannot be declined")
+
+	// The rules were priced without counting bundled items, as the bundles
+	// they gave kept changing.
+	ErrBundlesUnsettled = errors.New("cart: bundles did not settle")
+)
+
+type BundleMode int
+
+const (
+	MandatoryBundle  BundleMode = iota // Always bundled.
+	DeclinableBundle                   // Bundled unless the customer declines it.
+	OptInBundle                        // Only suggested until the customer accepts it.
+)
+
+func (m BundleMode) String() string {
+	switch m {
+	case MandatoryBundle:
+		return "mandatory"
+	case DeclinableBundle:
+		return "declinable"
+	case OptInBundle:
+		return "opt-in"
+	}
+
+	return "unknown"
+}
+
+// Implemented by rules whose bundled product the customer may decline or must
+// accept. Rules which don't implement it bundle products unconditionally. The
+// ID identifies the rule's bundle when choosing it, so choices survive the
+// rule set changing.
+type BundleModeRule interface {
+	Rule
+	ID() string
+	BundleMode() BundleMode
+}
+
+// Sets whether the product bundled by a rule is mandatory, declinable or only
+// suggested until accepted.
+func CreateBundleModeRule(id string, rule Rule, mode BundleMode) BundleModeRule {
+	return &bundleModeRule{id, rule, mode}
+}
+
+type bundleModeRule struct {
+	id   string
+	rule Rule
+	mode BundleMode
+}
+
+func (r *bundleModeRule) ID() string {
+	return r.id
+}
+
+func (r *bundleModeRule) BundleMode() BundleMode {
+	return r.mode
+}
+
+func (r *bundleModeRule) String() string {
+	return fmt.Sprintf("%v (%v)", r.rule, r.mode)
+}
+
+func (r *bundleModeRule) Dependencies() Dependencies {
+	return dependenciesOf(r.rule)
+}
+
+func (r *bundleModeRule) unwrap() Rule {
+	return r.rule
+}
+
+func (r *bundleModeRule) Evaluate(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	return r.rule.Evaluate(c)
+}
+
+// Returns the bundle mode of a rule, and the ID of the rule setting it.
+func bundleModeOf(rule Rule) (BundleMode, string) {
+	mode, id := MandatoryBundle, ""
+	walkRule(rule, func(r Rule) bool {
+		if br, ok := r.(BundleModeRule); ok {
+			mode, id = br.BundleMode(), br.ID()
+			return true
+		}
+		return false
+	})
+
+	return mode, id
+}
+
+// A product offered by a declinable or opt-in bundle rule. Choices are made
+// per offer, so two rules bundling the same product are chosen separately.
+type BundleOffer struct {
+	RuleID   string // ID of the BundleModeRule.
+	ProdCode string
+}
+
+// Returns whether a bundled product offered in the given mode goes in the cart,
+// as opposed to only being suggested.
+func bundleAccepted(bundleChoices map[BundleOffer]bool, offer BundleOffer, mode BundleMode) bool {
+	accepted, chosen := bundleChoices[offer]
+
+	switch mode {
+	case DeclinableBundle:
+		return !chosen || accepted
+	case OptInBundle:
+		return chosen && accepted
+	}
+
+	return true
+}
+
+// Returns the products offered by declinable or opt-in bundles which are not
+// in the cart, because they were declined or have not been accepted.
+func (c *defaultCart) SuggestedBundles() ProductCollectionType {
+	return c.suggestedBundles
+}
+
+// Returns ErrBundlesUnsettled if the rules counting bundled items were priced
+// without them when last evaluated, else nil. Such a cart cannot be checked
+// out, as its rules are misconfigured.
+func (c *defaultCart) PricingError() error {
+	return c.pricingErr
+}
+
+// Returns the optional bundles the rules offer, whether accepted or not, sorted
+// by rule ID and product.
+func (c *defaultCart) OptionalBundles() []BundleOffer {
+	offers := make([]BundleOffer, 0, len(c.optionalBundles))
+	for offer := range c.optionalBundles {
+		offers = append(offers, offer)
+	}
+
+	sort.Slice(offers, func(i, j int) bool {
+		if offers[i].RuleID != offers[j].RuleID {
+			return offers[i].RuleID < offers[j].RuleID
+		}
+		return offers[i].ProdCode < offers[j].ProdCode
+	})
+
+	return offers
+}
+
+// Accepts the product bundled by the bundle mode rule with the given ID. The
+// choice is kept until changed or the cart is cleared.
+func (c *defaultCart) AcceptBundle(ruleID, prodCode string) error {
+	return c.chooseBundle(BundleOffer{ruleID, prodCode}, true)
+}
+
+// Declines the product bundled by the bundle mode rule with the given ID. The
+// choice is kept until changed or the cart is cleared.
+func (c *defaultCart) DeclineBundle(ruleID, prodCode string) error {
+	return c.chooseBundle(BundleOffer{ruleID, prodCode}, false)
+}
+
+func (c *defaultCart) chooseBundle(offer BundleOffer, accept bool) error {
+	before := c.appliedRules
+	c.expireLocks()
+
+	if !c.optionalBundles[offer] {
+		c.emit(before)
+		for _, ar := range c.appliedRules {
+			if _, id := bundleModeOf(ar.Rule); id == offer.RuleID && ar.BundledProduct.code == offer.ProdCode {
+				return ErrMandatoryBundle
+			}
+		}
+		return ErrUnknownBundle
+	}
+
+	if accepted, chosen := c.bundleChoices[offer]; !chosen || accepted != accept {
+		c.checkpoint()
+		c.bundleChoices[offer] = accept
+		c.evaluateRules()
+	}
+
+	c.emit(before)
+	return nil
+}
+
+// The most times the rules are evaluated in one interaction when rules count
+// bundled items. Bundles which feed each other may otherwise never settle, in
+// which case the rules are priced as if none counted bundled items.
+const maxBundlePasses = 10
+
+// Makes a rule's conditions count the products bundled by other rules as if
+// they were in the cart, at their catalogue price. Ie. A free ult_small
+// counting toward a bulk discount on ult_small. Bundled products are never
+// discounted, as they weren't charged for, so the rule's actions only see the
+// products bought, and its discount is capped at what they cost. The exception
+// is PayForYOfEveryX, which counts bundled units toward its sets but only
+// makes units bought free. Ie. A free ult_small completing a 3 for 2.
+func CreateBundleAwareRule(rule Rule) Rule {
+	return &bundleAwareRule{rule}
+}
+
+type bundleAwareRule struct {
+	rule Rule
+}
+
+func (r *bundleAwareRule) String() string {
+	return fmt.Sprintf("%v (including bundled items)", r.rule)
+}
+
+func (r *bundleAwareRule) unwrap() Rule {
+	return r.rule
+}
+
+func (r *bundleAwareRule) Evaluate(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	discount, bundledProduct = r.rule.Evaluate(c)
+	if charged := spend(chargedItems(c), UndiscountedSpend, nil); discount > charged {
+		discount = charged
+	}
+
+	return discount, bundledProduct
+}
+
+func countsBundledItems(rule Rule) bool {
+	return walkRule(rule, func(r Rule) bool {
+		_, ok := r.(*bundleAwareRule)
+		return ok
+	})
+}
+
+func (e *pricingEngine) hasBundleAwareRules() bool {
+	for _, traits := range e.traits {
+		if traits.countsBundles {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sameBundles(a, b map[int]BundledProduct) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i, bp := range a {
+		if b[i] != bp {
+			return false
+		}
+	}
+
+	return true
+}
+
+// A view of a basket whose items include products bundled by rules.
+type bundledItemsView struct {
+	Basket
+	items ProductCollectionType
+}
+
+func (v *bundledItemsView) Items() ProductCollectionType {
+	return v.items
+}
+
+func (v *bundledItemsView) charged() Basket {
+	return v.Basket
+}
+
+// Implemented by actions which count bundled units as well as those bought,
+// while only discounting those bought.
+type bundleCountingAction interface {
+	applyCounting(all, charged Basket) (PriceType, BundledProduct)
+}
+
+// Applies an action to a basket which may include products bundled by other
+// rules.
+func applyCharged(action Action, c Basket) (PriceType, BundledProduct) {
+	if a, ok := action.(bundleCountingAction); ok {
+		return a.applyCounting(c, chargedItems(c))
+	}
+
+	return action.Apply(chargedItems(c))
+}
+
+// Returns the basket without the products bundled by other rules, being the
+// products an action may discount.
+func chargedItems(c Basket) Basket {
+	if v, ok := c.(interface{ charged() Basket }); ok {
+		return v.charged()
+	}
+
+	return c
+}
+
+// Returns a view of the basket including the products bundled by every rule
+// but the one being evaluated, so a rule never counts its own bundle.
+func (e *pricingEngine) withBundledItems(b *basket, bundled map[int]BundledProduct, index int) Basket {
+	items := b.items.copy()
+	for i, bp := range bundled {
+		if i == index {
+			continue
+		}
+
+		if v, ok := items[bp.code]; ok {
+			v.add(bp.count)
+		} else if product, ok := e.catalogue[bp.code]; ok {
+			items[bp.code] = &ProductCount{product, bp.count}
+		}
+	}
+
+	return &bundledItemsView{b, items}
+}
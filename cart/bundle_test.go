@@ -0,0 +1,251 @@
+package cart
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_BundleMode_WHEN_DeclinableBundleDeclined_EXPECT_SuggestedOnly(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rules := []Rule{CreateBundleModeRule("medium-data", CreateBundleRule("ult_medium", 1, "1gb", 1), DeclinableBundle)}
+	c := CreateCart(rules, catalogue)
+
+	c.Add(catalogue["ult_medium"])
+	checkCartContainsNBundledProductsWithCode(t, c, "1gb", 1)
+
+	if err := c.DeclineBundle("medium-data", "1gb"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(c.BundledItems()) != 0 {
+		t.Errorf("BundledItems=%v", c.BundledItems())
+	}
+
+	if v, ok := c.SuggestedBundles()["1gb"]; !ok || v.count != 1 {
+		t.Errorf("SuggestedBundles=%v", c.SuggestedBundles())
+	}
+
+	// The choice survives rules being re-evaluated.
+	c.Add(catalogue["ult_medium"])
+	if len(c.BundledItems()) != 0 || c.SuggestedBundles()["1gb"].count != 2 {
+		t.Errorf("BundledItems=%v SuggestedBundles=%v", c.BundledItems(), c.SuggestedBundles())
+	}
+
+	c.AcceptBundle("medium-data", "1gb")
+	checkCartContainsNBundledProductsWithCode(t, c, "1gb", 2)
+}
+
+func Test_BundleMode_WHEN_OptInBundle_EXPECT_BundledOnlyOnceAccepted(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rules := []Rule{CreateBundleModeRule("medium-data", CreateBundleRule("ult_medium", 1, "1gb", 1), OptInBundle)}
+	c := CreateCart(rules, catalogue)
+
+	c.Add(catalogue["ult_medium"])
+	if len(c.BundledItems()) != 0 || len(c.SuggestedBundles()) != 1 {
+		t.Errorf("BundledItems=%v SuggestedBundles=%v", c.BundledItems(), c.SuggestedBundles())
+	}
+
+	if err := c.AcceptBundle("medium-data", "1gb"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	checkCartContainsNBundledProductsWithCode(t, c, "1gb", 1)
+	if len(c.SuggestedBundles()) != 0 {
+		t.Errorf("SuggestedBundles=%v", c.SuggestedBundles())
+	}
+
+	c.Undo()
+	if len(c.BundledItems()) != 0 {
+		t.Errorf("BundledItems=%v", c.BundledItems())
+	}
+}
+
+func Test_BundleMode_WHEN_MandatoryOrUnknownBundleDeclined_EXPECT_Error(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rules := []Rule{CreateBundleModeRule("medium-data", CreateBundleRule("ult_medium", 1, "1gb", 1), MandatoryBundle)}
+	c := CreateCart(rules, catalogue)
+
+	c.Add(catalogue["ult_medium"])
+
+	if err := c.DeclineBundle("medium-data", "1gb"); err != ErrMandatoryBundle {
+		t.Errorf("Error=%v, Expected=%v", err, ErrMandatoryBundle)
+	}
+
+	if err := c.AcceptBundle("medium-data", "ult_small"); err != ErrUnknownBundle {
+		t.Errorf("Error=%v, Expected=%v", err, ErrUnknownBundle)
+	}
+
+	checkCartContainsNBundledProductsWithCode(t, c, "1gb", 1)
+}
+
+func Test_BundleMode_WHEN_TwoBundlesOfSameProduct_EXPECT_ChosenSeparately(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rules := []Rule{
+		CreateBundleModeRule("medium-data", CreateBundleRule("ult_medium", 1, "1gb", 1), DeclinableBundle),
+		CreateBundleModeRule("large-data", CreateBundleRule("ult_large", 1, "1gb", 2), OptInBundle),
+	}
+	c := CreateCart(rules, catalogue)
+	c.Add(catalogue["ult_medium"])
+	c.Add(catalogue["ult_large"])
+
+	expected := []BundleOffer{{"large-data", "1gb"}, {"medium-data", "1gb"}}
+	if !reflect.DeepEqual(c.OptionalBundles(), expected) {
+		t.Errorf("OptionalBundles=%v, Expected=%v", c.OptionalBundles(), expected)
+	}
+
+	checkCartContainsNBundledProductsWithCode(t, c, "1gb", 1)
+
+	// Accepting the opt-in bundle leaves the declinable one as it was.
+	if err := c.AcceptBundle("large-data", "1gb"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	checkCartContainsNBundledProductsWithCode(t, c, "1gb", 3)
+
+	// Declining the declinable bundle leaves the opt-in one accepted.
+	if err := c.DeclineBundle("medium-data", "1gb"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	checkCartContainsNBundledProductsWithCode(t, c, "1gb", 2)
+
+	if v := c.SuggestedBundles()["1gb"]; v == nil || v.count != 1 {
+		t.Errorf("SuggestedBundles=%v", c.SuggestedBundles())
+	}
+}
+
+func Test_BundleAwareRule_WHEN_BundledItemMeetsCondition_EXPECT_ChargedItemsDiscounted(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	bundle := CreateBundleRule("ult_large", 1, "ult_small", 1)
+
+	c := CreateCart([]Rule{bundle, CreateBulkDiscountRule("ult_small", 3, 100)}, catalogue)
+	aware := CreateCart([]Rule{bundle, CreateBundleAwareRule(CreateBulkDiscountRule("ult_small", 3, 100))}, catalogue)
+
+	for _, cart := range []Cart{c, aware} {
+		cart.Add(catalogue["ult_small"])
+		cart.Add(catalogue["ult_small"])
+		cart.Add(catalogue["ult_large"])
+	}
+
+	if expected := PriceType(2*2490 + 4490); c.Total() != expected {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), expected)
+	}
+
+	// The free ult_small counts toward the bulk discount, but only the two
+	// bought are discounted.
+	if expected := PriceType(2*2390 + 4490); aware.Total() != expected {
+		t.Errorf("CartTotal=%d, Expected=%d", aware.Total(), expected)
+	}
+
+	checkCartContainsNProductsWithCode(t, aware, "ult_small", 2)
+}
+
+func Test_BundleAwareRule_WHEN_BundledItemCompletesSet_EXPECT_XForYApplied(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	bundle := CreateBundleRule("ult_large", 1, "ult_small", 1)
+
+	for _, tc := range []struct {
+		small          int
+		plain, bundled PriceType
+	}{
+		{2, 4490 + 2*2490, 4490 + 2490},
+		{4, 4490 + 3*2490, 4490 + 3*2490},
+		{5, 4490 + 4*2490, 4490 + 3*2490},
+	} {
+		c := CreateCart([]Rule{bundle, CreateXForYRule("ult_small", 3, 2)}, catalogue)
+		aware := CreateCart([]Rule{bundle, CreateBundleAwareRule(CreateXForYRule("ult_small", 3, 2))}, catalogue)
+
+		for _, cart := range []Cart{c, aware} {
+			cart.Add(catalogue["ult_large"])
+			for i := 0; i < tc.small; i++ {
+				cart.Add(catalogue["ult_small"])
+			}
+		}
+
+		if c.Total() != tc.plain || aware.Total() != tc.bundled {
+			t.Errorf("%d ult_small: CartTotal=%d %d, Expected=%d %d", tc.small, c.Total(), aware.Total(), tc.plain, tc.bundled)
+		}
+	}
+}
+
+func Test_BundleAwareRule_WHEN_BundledItemFree_EXPECT_NotDiscountedAgain(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	bundle := CreateBundleRule("ult_medium", 1, "1gb", 1)
+
+	for _, tc := range []struct {
+		rule     Rule
+		expected PriceType
+	}{
+		// Five 1gb make one set, of which a 1gb bought is free.
+		{CreateXForYRule("1gb", 3, 2), 3*2990 + 990},
+		{CreateBulkDiscountRule("1gb", 3, 500), 3*2990 + 2*490},
+		{CreateCheapestItemFreeRule(2, "ult_medium", "1gb"), 3*2990 + 990},
+		{CreateConditionalRule(HasPromoCode("FREE"), PercentOffItems(100)), 0},
+	} {
+		c := CreateCart([]Rule{bundle, CreateBundleAwareRule(tc.rule)}, catalogue)
+		for i := 0; i < 3; i++ {
+			c.Add(catalogue["ult_medium"])
+		}
+		c.Add(catalogue["1gb"])
+		c.Add(catalogue["1gb"])
+		c.AddPromoCode("FREE")
+
+		if c.Total() != tc.expected {
+			t.Errorf("Rule=%v CartTotal=%d, Expected=%d", tc.rule, c.Total(), tc.expected)
+		}
+	}
+}
+
+func Test_BundleAwareRule_WHEN_BundlesFeedEachOther_EXPECT_BundledItemsNotBundleMore(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rules := []Rule{
+		CreateBundleAwareRule(CreateBundleRule("ult_medium", 1, "ult_small", 1)),
+		CreateBundleAwareRule(CreateBundleRule("ult_small", 1, "ult_medium", 1)),
+	}
+
+	for _, n := range []int{1, 2} {
+		c := CreateCart(rules, catalogue)
+		for i := 0; i < n; i++ {
+			c.Add(catalogue["ult_medium"])
+		}
+
+		if bundled := c.BundledItems(); len(bundled) != 1 || bundled["ult_small"] == nil || bundled["ult_small"].count != uint16(n) {
+			t.Errorf("BundledItems=%v, Expected %d ult_small", bundled, n)
+		}
+	}
+}
+
+func Test_BundleAwareRule_WHEN_BundlesNeverSettle_EXPECT_ErrorAndNoExtraItems(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	var rules []Rule
+	for _, source := range []string{
+		"bundle = \"ult_small\"\nbundle_count = count(\"ult_medium\")",
+		"bundle = \"ult_medium\"\nbundle_count = count(\"ult_small\")",
+	} {
+		rule, err := CreateScriptRule(source, ScriptLimits{})
+		if err != nil {
+			t.Fatalf("Error=%v", err)
+		}
+		rules = append(rules, CreateBundleAwareRule(rule))
+	}
+
+	c := CreateCart(rules, catalogue)
+	c.Add(catalogue["ult_medium"])
+
+	if c.PricingError() != ErrBundlesUnsettled {
+		t.Errorf("PricingError=%v, Expected=%v", c.PricingError(), ErrBundlesUnsettled)
+	}
+
+	// Priced as if neither rule counted bundled items.
+	if bundled := c.BundledItems(); len(bundled) != 1 || bundled["ult_small"] == nil || bundled["ult_small"].count != 1 {
+		t.Errorf("BundledItems=%v, Expected 1 ult_small", bundled)
+	}
+
+	if _, err := c.Checkout(); err != ErrBundlesUnsettled {
+		t.Errorf("Error=%v, Expected=%v", err, ErrBundlesUnsettled)
+	}
+
+	c.Clear()
+
+	if c.PricingError() != nil {
+		t.Errorf("PricingError=%v, Expected=nil", c.PricingError())
+	}
+}
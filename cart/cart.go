@@ -2,30 +2,79 @@ package cart
 
 import (
 	"fmt"
+	"time"
 )
 
 type Cart interface {
-	Add(Product)
+	Basket
+	Add(Product) error
 	Remove(Product)
-	AddPromoCode(string)
+	AddPromoCode(string) error
 	RemovePromoCode(string)
 	Clear()
-	Items() ProductCollectionType
 	BundledItems() ProductCollectionType
-	PromoCodes() []string
-	Total() PriceType
+	Checkout() (Order, error)
+	Undo() bool
+	Redo() bool
+	Allowance(prodCode string) int
+	Violations() []*RelationError
+	AddLine(p Product, attributes map[string]string) (string, error)
+	RemoveLine(id string) error
+	SetLineAttribute(id, key, value string) error
+	Lines() []LineItem
+	SetCustomer(Customer)
+	SelectGift(ruleID, prodCode string) error
+	SuggestedBundles() ProductCollectionType
+	OptionalBundles() []BundleOffer
+	AcceptBundle(ruleID, prodCode string) error
+	DeclineBundle(ruleID, prodCode string) error
+	UpsellHints() []UpsellHint
+	PromoDiagnoses() []PromoDiagnosis
+	SetAsidePromoCodes() []SetAsidePromo
+	PricingError() error
 }
 
-func CreateCart(rules []Rule, catalogue Catalogue) Cart {
-	return &defaultCart{
+// Configures optional behaviour of a cart on construction.
+type CartOption func(*defaultCart)
+
+// Sets the source of the current time. Defaults to time.Now.
+func WithClock(now func() time.Time) CartOption {
+	return func(c *defaultCart) {
+		c.now = now
+	}
+}
+
+func CreateCart(rules []Rule, catalogue Catalogue, opts ...CartOption) Cart {
+	c := &defaultCart{
 		catalogue:         catalogue,
 		products:          make(ProductCollectionType),
 		bundleProducts:    make(ProductCollectionType),
+		suggestedBundles:  make(ProductCollectionType),
 		promoCodes:        make(map[string]bool),
 		rules:             rules,
 		undiscountedTotal: 0,
 		discount:          0,
+		now:               time.Now,
+		priceLocks:        make(map[string]time.Time),
+		ruleLocks:         make(map[int]time.Time),
+		undoLimit:         defaultUndoLimit,
+		giftSelections:    make(map[string]string),
+		bundleChoices:     make(map[BundleOffer]bool),
+		changedProducts:   make(map[string]bool),
+		changedPromoCodes: make(map[string]bool),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.customerType != "" {
+		c.customer.Segment = c.customerType
 	}
+
+	c.engine = createPricingEngine(catalogue, rules, !c.fullEvaluation, c.promoPolicy.Normalize)
+
+	return c
 }
 
 type defaultCart struct {
@@ -36,9 +85,80 @@ type defaultCart struct {
 	rules             []Rule
 	undiscountedTotal PriceType // Total of Products in cart without offers/promotions applied.
 	discount          PriceType // Discount applied due to triggered rules.
+	appliedRules      []AppliedRule
+	now               func() time.Time
+	lockWindow        time.Duration        // Zero disables price locking.
+	priceLocks        map[string]time.Time // Product code => lock expiry.
+	ruleLocks         map[int]time.Time    // Rule index => lock expiry.
+	onReprice         func(RepriceNotice)
+	listeners         []Listener
+	undoLimit         int
+	undoStack         []cartSnapshot
+	redoStack         []cartSnapshot
+	constraints       Constraints
+	customer          Customer
+	relations         Relations
+	lines             []*cartLine // In the order they were added.
+	nextLineID        int
+	giftSelections    map[string]string // Gift rule ID => selected product code.
+	suggestedBundles  ProductCollectionType
+	optionalBundles   map[BundleOffer]bool // Offered by declinable or opt-in bundles.
+	bundleChoices     map[BundleOffer]bool // Accepted (true) or declined (false).
+	fullEvaluation    bool
+	engine            *pricingEngine
+	promoPolicy       PromoPolicy
+	setAside          map[string]SetAsidePromo // Promo codes held but not applied, by code.
+	changedProducts   map[string]bool
+	changedPromoCodes map[string]bool
+	pricingErr        error
+	customerType      string  // Set by WithCustomerType, overriding the customer's Segment.
+	promoEvents       []Event // Promo codes set aside or applied by evaluating rules, until emitted.
+}
+
+func (c *defaultCart) Add(p Product) error {
+	_, err := c.AddLine(p, nil)
+	return err
 }
 
-func (c *defaultCart) Add(p Product) {
+// Adds a unit of the product as a new line with the given attributes, returning
+// the ID of the line.
+func (c *defaultCart) AddLine(p Product, attributes map[string]string) (string, error) {
+	before := c.appliedRules
+	c.expireLocks()
+
+	if err := c.checkRelations(p.Code); err != nil {
+		c.emit(before)
+		return "", err
+	}
+
+	// Replacements are rolled back if the product cannot then be added.
+	replacements := c.replacements(p.Code)
+	var saved cartSnapshot
+	if len(replacements) > 0 || c.undoLimit > 0 {
+		saved = c.snapshot()
+	}
+
+	c.nextLineID++
+	id := fmt.Sprintf("line-%d", c.nextLineID)
+	events := []Event{{Type: ItemAdded, Product: p, LineID: id}}
+	for _, replaced := range replacements {
+		removed, removedID := c.removeUnit(replaced.Code)
+		events = append(events, Event{Type: ItemRemoved, Product: removed, LineID: removedID})
+	}
+
+	if err := c.checkConstraints(p.Code); err != nil {
+		if len(replacements) > 0 {
+			c.restore(saved)
+		}
+
+		c.nextLineID--
+		c.emit(before)
+		return "", err
+	}
+
+	c.saveUndo(saved)
+	c.lines = append(c.lines, &cartLine{id, p.Code, copyAttributes(attributes)})
+
 	if v, ok := c.products[p.Code]; !ok {
 		//fmt.Printf("Adding %s to the cart. Count=1\n", p.Code)
 		c.products[p.Code] = &ProductCount{p, 1}
@@ -46,50 +166,143 @@ func (c *defaultCart) Add(p Product) {
 		v.count++
 		//fmt.Printf("Adding %s to the cart. Count=%d\n", p.Code, v.count)
 	}
+	c.productChanged(p.Code)
 
-	c.undiscountedTotal += p.Price
+	// Units of the same product share a price, which may be locked.
+	c.undiscountedTotal += c.products[p.Code].product.Price
+	c.lock(p.Code)
 	c.evaluateRules()
+	c.emit(before, events...)
+
+	return id, nil
 }
 
 func (c *defaultCart) Remove(p Product) {
-	if v, ok := c.products[p.Code]; ok {
-		v.count--
-		if v.count == 0 {
-			//fmt.Printf("Removed last %s from the cart.\n", p.Code)
-			delete(c.products, p.Code)
-		} else {
-			//fmt.Printf("Removing %s from the cart. Count=%d\n", p.Code, v.count)
-		}
+	before := c.appliedRules
+	c.expireLocks()
 
-		// TODO: Should put validation here to ensure it doesn't ever go negative.
-		c.undiscountedTotal -= p.Price
+	if _, ok := c.products[p.Code]; ok {
+		c.checkpoint()
+		removed, id := c.removeUnit(p.Code)
 		c.evaluateRules()
+		c.emit(before, Event{Type: ItemRemoved, Product: removed, LineID: id})
+	} else {
+		c.emit(before)
 	}
 }
 
-func (c *defaultCart) AddPromoCode(code string) {
+// Removes the most recently added line of a product known to be in the cart.
+func (c *defaultCart) removeUnit(prodCode string) (Product, string) {
+	for i := len(c.lines) - 1; i >= 0; i-- {
+		if c.lines[i].code == prodCode {
+			return c.removeLineAt(i)
+		}
+	}
+
+	// Unreachable whilst every unit has a line.
+	return Product{}, ""
+}
+
+func (c *defaultCart) removeLineAt(index int) (Product, string) {
+	line := c.lines[index]
+	c.lines = append(c.lines[:index:index], c.lines[index+1:]...)
+
+	v := c.products[line.code]
+	v.count--
+	c.productChanged(line.code)
+	if v.count == 0 {
+		//fmt.Printf("Removed last %s from the cart.\n", line.code)
+		delete(c.products, line.code)
+	} else {
+		//fmt.Printf("Removing %s from the cart. Count=%d\n", line.code, v.count)
+	}
+
+	// TODO: Should put validation here to ensure it doesn't ever go negative.
+	c.undiscountedTotal -= v.product.Price
+
+	return v.product, line.id
+}
+
+// Adds a promo code, once normalized by the cart's PromoPolicy. Returns a
+// *PromoError if the policy rejects it.
+func (c *defaultCart) AddPromoCode(entered string) error {
+	before := c.appliedRules
+	c.expireLocks()
+
+	code, err := c.checkPromoCode(entered)
+	if err != nil {
+		c.emit(before, Event{Type: PromoRejected, PromoCode: entered, Err: err})
+		return err
+	}
+
+	added := !c.promoCodes[code]
+	if added {
+		c.checkpoint()
+	}
+
 	c.promoCodes[code] = true
+	c.promoChanged(code)
+	c.lock("")
 	c.evaluateRules()
+
+	// A code set aside is reported as PromoSetAside instead.
+	var events []Event
+	if _, setAside := c.setAside[code]; added && !setAside {
+		events = append(events, Event{Type: PromoApplied, PromoCode: code})
+	}
+	c.emit(before, events...)
+
+	return nil
 }
 
 func (c *defaultCart) RemovePromoCode(code string) {
+	before := c.appliedRules
+	c.expireLocks()
+	code = c.promoPolicy.Normalize.apply(code)
+
+	var events []Event
+	if c.promoCodes[code] {
+		c.checkpoint()
+		events = append(events, Event{Type: PromoRemoved, PromoCode: code})
+	}
+
 	delete(c.promoCodes, code)
+	c.promoChanged(code)
 	c.evaluateRules()
+	c.emit(before, events...)
 }
 
+// Returns the promo codes applied, excluding any set aside.
 func (c *defaultCart) PromoCodes() []string {
 	codes := []string{}
 	for k, _ := range c.promoCodes {
-		codes = append(codes, k)
+		if _, ok := c.setAside[k]; !ok {
+			codes = append(codes, k)
+		}
 	}
 
 	return codes
 }
 
 func (c *defaultCart) Clear() {
+	before := c.appliedRules
+	c.checkpoint()
 	c.products = make(ProductCollectionType)
+	c.lines = nil
 	c.bundleProducts = make(ProductCollectionType)
 	c.promoCodes = make(map[string]bool)
+	c.bundleChoices = make(map[BundleOffer]bool)
+	c.undiscountedTotal = 0
+	c.priceLocks = make(map[string]time.Time)
+	c.ruleLocks = make(map[int]time.Time)
+	c.engine.invalidate()
+	c.evaluateRules()
+	c.emit(before, Event{Type: Cleared})
+}
+
+// Returns the catalogue the cart was created with. Callers must not modify it.
+func (c *defaultCart) Catalogue() Catalogue {
+	return c.catalogue
 }
 
 func (c *defaultCart) Items() ProductCollectionType {
@@ -105,24 +318,38 @@ func (c *defaultCart) Total() PriceType {
 }
 
 func (c *defaultCart) evaluateRules() {
-	c.discount = 0
-	c.bundleProducts = make(ProductCollectionType)
+	if c.promoPolicy.SelectBest {
+		c.selectBestPromoCodes()
+	}
 
-	for _, rule := range c.rules {
-		discount, bp := rule.Evaluate(c)
-		c.discount += discount
-
-		if bp.count != 0 && bp.code != "" {
-
-			if v, ok := c.bundleProducts[bp.code]; !ok {
-				if product, ok := c.catalogue[bp.code]; !ok {
-					fmt.Errorf("Failed to find %v in product map. This implies a rule is setup for a product which doesnt exist.\n", bp.code)
-				} else {
-					c.bundleProducts[bp.code] = &ProductCount{product, bp.count}
-				}
-			} else {
-				v.count += bp.count
-			}
-		}
+	stale := c.engine.staleRules(c.changedProducts, c.changedPromoCodes)
+	c.changedProducts = make(map[string]bool)
+	c.changedPromoCodes = make(map[string]bool)
+
+	now := c.now()
+	result := c.engine.price(c.basket(), c.bundleChoices, stale, func(i int, rule Rule) bool {
+		return c.ruleAvailable(i, rule, now)
+	})
+
+	c.discount = result.Discount
+	c.bundleProducts = result.BundledItems
+	c.suggestedBundles = result.SuggestedBundles
+	c.optionalBundles = result.optionalBundles
+	c.appliedRules = result.AppliedRules
+	c.pricingErr = result.Err
+	c.revalidateGifts()
+}
+
+// Returns the contents of the cart to be priced. It shares the cart's state,
+// so must not outlive the interaction in progress.
+func (c *defaultCart) basket() *basket {
+	return &basket{
+		engine:            c.engine,
+		items:             c.products,
+		promoCodes:        sortedCodes(c.PromoCodes()),
+		normalize:         c.promoPolicy.Normalize,
+		customer:          c.customer,
+		giftSelections:    c.giftSelections,
+		undiscountedTotal: c.undiscountedTotal,
 	}
 }
@@ -218,3 +218,17 @@ func Test_Cart_WHEN_PromoCodeRemoved_EXPECT_CartRemovesPromoCode(t *testing.T) {
 		t.Errorf("ActualPromoCodes=%v ExpectedPromoCodes=0", c.PromoCodes())
 	}
 }
+
+func Test_Cart_GIVEN_NonEmptyCart_WHEN_Cleared_EXPECT_TotalIsZero(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(CreateDefaultRules(), catalogue)
+
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+	c.Clear()
+
+	if c.Total() != 0 {
+		t.Errorf("CartTotal=%d, Expected=0", c.Total())
+	}
+}
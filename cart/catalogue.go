@@ -10,3 +10,13 @@ func CreateDefaultCatalogue() Catalogue {
 		"1gb":        Product{"1gb", "1GB Data-pack", 990},
 	}
 }
+
+// Groups product codes by category. "category" => [product codes]
+type Categories map[string][]string
+
+func CreateDefaultCategories() Categories {
+	return Categories{
+		"sim":  []string{"ult_small", "ult_medium", "ult_large"},
+		"data": []string{"1gb"},
+	}
+}
@@ -0,0 +1,562 @@
+package cart
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A reusable test of the state of a cart.
+type Condition interface {
+	Satisfied(Basket) bool
+}
+
+// A reusable outcome of a rule, given the state of a cart.
+type Action interface {
+	Apply(Basket) (discount PriceType, bundledProduct BundledProduct)
+}
+
+func And(conditions ...Condition) Condition {
+	return &andCondition{conditions}
+}
+
+func Or(conditions ...Condition) Condition {
+	return &orCondition{conditions}
+}
+
+func Not(condition Condition) Condition {
+	return &notCondition{condition}
+}
+
+// Satisfied when the cart holds at least n units of the product.
+func MinQuantity(prodCode string, n uint16) Condition {
+	return &minQuantityCondition{prodCode, n}
+}
+
+// Satisfied when the promo code has been added to the cart.
+func HasPromoCode(code string) Condition {
+	return &promoCodeCondition{code}
+}
+
+// Satisfied when the cart's customer is accepted by the filter.
+func ForCustomers(filter CustomerFilter) Condition {
+	return &customerCondition{filter}
+}
+
+// For every x units of the product, only y are paid for.
+func PayForYOfEveryX(prodCode string, x, y uint16) Action {
+	return &xForYAction{prodCode, x, y}
+}
+
+// Discounts every unit of the product by a fixed amount.
+func DiscountEachUnit(prodCode string, discountAbs PriceType) Action {
+	return &unitDiscountAction{prodCode, discountAbs}
+}
+
+// Bundles itemsToGet of getProdCode for every itemsToBuy of buyProdCode.
+func BundleWithEvery(buyProdCode string, itemsToBuy uint16, getProdCode string, itemsToGet uint16) Action {
+	return &bundleAction{buyProdCode, itemsToBuy, getProdCode, itemsToGet}
+}
+
+// Discounts the total of the items in the cart by a percentage.
+func PercentOffItems(discountPct int8) Action {
+	return &percentOffItemsAction{discountPct}
+}
+
+func joinConditions(conditions []Condition, sep string) string {
+	s := make([]string, len(conditions))
+	for i, c := range conditions {
+		s[i] = fmt.Sprint(c)
+	}
+
+	return "(" + strings.Join(s, sep) + ")"
+}
+
+type andCondition struct {
+	conditions []Condition
+}
+
+func (c *andCondition) String() string {
+	return joinConditions(c.conditions, " and ")
+}
+
+func (c *andCondition) Dependencies() Dependencies {
+	return mergeConditions(c.conditions)
+}
+
+func (c *andCondition) Explain(cart Basket) (PromoReason, bool) {
+	for _, cond := range c.conditions {
+		if reason, ok := explainCondition(cond, cart); ok {
+			return reason, true
+		}
+	}
+
+	return PromoReason{}, false
+}
+
+func (c *andCondition) Satisfied(cart Basket) bool {
+	for _, cond := range c.conditions {
+		if !cond.Satisfied(cart) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type orCondition struct {
+	conditions []Condition
+}
+
+func (c *orCondition) String() string {
+	return joinConditions(c.conditions, " or ")
+}
+
+func (c *orCondition) Dependencies() Dependencies {
+	return mergeConditions(c.conditions)
+}
+
+func (c *orCondition) Satisfied(cart Basket) bool {
+	for _, cond := range c.conditions {
+		if cond.Satisfied(cart) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type notCondition struct {
+	condition Condition
+}
+
+func (c *notCondition) String() string {
+	return fmt.Sprintf("not %v", c.condition)
+}
+
+func (c *notCondition) Dependencies() Dependencies {
+	return dependenciesOf(c.condition)
+}
+
+func (c *notCondition) Explain(cart Basket) (PromoReason, bool) {
+	if pc, ok := c.condition.(*promoCodeCondition); ok && pc.Satisfied(cart) {
+		return PromoReason{PromoExcluded, fmt.Sprintf("cannot be combined with promo code %s", pc.code)}, true
+	}
+
+	return PromoReason{}, false
+}
+
+func (c *notCondition) Satisfied(cart Basket) bool {
+	return !c.condition.Satisfied(cart)
+}
+
+type minQuantityCondition struct {
+	prodCode string
+	n        uint16
+}
+
+func (c *minQuantityCondition) String() string {
+	return fmt.Sprintf("at least %d %s", c.n, c.prodCode)
+}
+
+func (c *minQuantityCondition) Dependencies() Dependencies {
+	return productDependencies(c.prodCode)
+}
+
+func (c *minQuantityCondition) Satisfied(cart Basket) bool {
+	v, ok := cart.Items()[c.prodCode]
+	return ok && v.count >= c.n
+}
+
+type promoCodeCondition struct {
+	code string
+}
+
+func (c *promoCodeCondition) String() string {
+	return fmt.Sprintf("promo code %s", c.code)
+}
+
+func (c *promoCodeCondition) Dependencies() Dependencies {
+	return Dependencies{PromoCodes: []string{c.code}}
+}
+
+func (c *promoCodeCondition) Satisfied(cart Basket) bool {
+	return cart.HasPromoCode(c.code)
+}
+
+type customerCondition struct {
+	filter CustomerFilter
+}
+
+func (c *customerCondition) String() string {
+	return "eligible customer"
+}
+
+func (c *customerCondition) Dependencies() Dependencies {
+	return Dependencies{}
+}
+
+func (c *customerCondition) Satisfied(cart Basket) bool {
+	return c.filter(cart.Customer())
+}
+
+type xForYAction struct {
+	prodCode string
+	x        uint16
+	y        uint16
+}
+
+func (a *xForYAction) String() string {
+	return fmt.Sprintf("pay for %d of every %d %s", a.y, a.x, a.prodCode)
+}
+
+func (a *xForYAction) Dependencies() Dependencies {
+	return productDependencies(a.prodCode)
+}
+
+func (a *xForYAction) Apply(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	return a.applyCounting(c, c)
+}
+
+// Counts the sets among all units, but makes only units charged for free.
+func (a *xForYAction) applyCounting(all, charged Basket) (PriceType, BundledProduct) {
+	v, ok := charged.Items()[a.prodCode]
+	if !ok {
+		return 0, BundledProduct{}
+	}
+
+	timesToApplyDiscount := int(all.Items()[a.prodCode].count / a.x)
+	free := int(a.x-a.y) * timesToApplyDiscount
+	if free > int(v.count) {
+		free = int(v.count)
+	}
+
+	return PriceType(free) * v.product.Price, BundledProduct{}
+}
+
+type unitDiscountAction struct {
+	prodCode    string
+	discountAbs PriceType
+}
+
+func (a *unitDiscountAction) String() string {
+	return fmt.Sprintf("%d off each %s", a.discountAbs, a.prodCode)
+}
+
+func (a *unitDiscountAction) Dependencies() Dependencies {
+	return productDependencies(a.prodCode)
+}
+
+func (a *unitDiscountAction) Apply(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	v, ok := c.Items()[a.prodCode]
+	if !ok {
+		return 0, BundledProduct{}
+	}
+
+	return PriceType(v.count) * a.discountAbs, BundledProduct{}
+}
+
+type bundleAction struct {
+	buyProdCode string
+	itemsToBuy  uint16
+	getProdCode string
+	itemsToGet  uint16
+}
+
+func (a *bundleAction) String() string {
+	return fmt.Sprintf("%d free %s with every %d %s", a.itemsToGet, a.getProdCode, a.itemsToBuy, a.buyProdCode)
+}
+
+func (a *bundleAction) Dependencies() Dependencies {
+	return productDependencies(a.buyProdCode)
+}
+
+func (a *bundleAction) Apply(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	v, ok := c.Items()[a.buyProdCode]
+	if !ok || v.count < a.itemsToBuy {
+		return 0, BundledProduct{}
+	}
+
+	return 0, BundledProduct{a.getProdCode, (v.count / a.itemsToBuy) * a.itemsToGet}
+}
+
+type percentOffItemsAction struct {
+	discountPct int8
+}
+
+func (a *percentOffItemsAction) String() string {
+	return fmt.Sprintf("%d%% off cart", a.discountPct)
+}
+
+func (a *percentOffItemsAction) Dependencies() Dependencies {
+	return Dependencies{AnyProduct: true}
+}
+
+func (a *percentOffItemsAction) Apply(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	var cartTotal PriceType = 0
+	for _, v := range c.Items() {
+		cartTotal += PriceType(v.count) * v.product.Price
+	}
+
+	return percentageOfPrice(cartTotal, a.discountPct), BundledProduct{}
+}
+
+// Satisfied when the cart holds at least n units of the given products in
+// total. No products means any product.
+func MinUnits(n uint16, prodCodes ...string) Condition {
+	return &minUnitsCondition{prodCodes, n}
+}
+
+// Discounts every nth unit of the given products by a percentage. Units are
+// ordered by descending price, so discounts fall on the cheaper units.
+func DiscountEveryNth(n uint16, discountPct int8, prodCodes ...string) Action {
+	return &everyNthAction{prodCodes, n, discountPct}
+}
+
+// Makes the cheapest unit of the given products free.
+func CheapestFree(prodCodes ...string) Action {
+	return &cheapestFreeAction{prodCodes}
+}
+
+// Satisfied when the cart holds at least one complete set of products. The set
+// maps product codes to quantities.
+func HasCompleteSet(set map[string]uint16) Condition {
+	return &completeSetCondition{copySet(set)}
+}
+
+// Prices each complete set of products in the cart at a fixed price. Sets
+// which would cost less than the fixed price are not discounted.
+func FixedPriceForSets(set map[string]uint16, price PriceType) Action {
+	return &fixedPriceSetAction{copySet(set), price}
+}
+
+// A single unit of a product in the cart.
+type unit struct {
+	code  string
+	price PriceType
+}
+
+// Expands the items matching the product codes (or all items if none are
+// given) into units, ordered by descending price and then by product code so
+// that equal prices are broken deterministically.
+func expandUnits(items ProductCollectionType, prodCodes []string) []unit {
+	var codes []string
+	for code := range items {
+		if len(prodCodes) == 0 || contains(prodCodes, code) {
+			codes = append(codes, code)
+		}
+	}
+
+	sort.Slice(codes, func(i, j int) bool {
+		pi, pj := items[codes[i]].product.Price, items[codes[j]].product.Price
+		if pi != pj {
+			return pi > pj
+		}
+		return codes[i] < codes[j]
+	})
+
+	var units []unit
+	for _, code := range codes {
+		for i := uint16(0); i < items[code].count; i++ {
+			units = append(units, unit{code, items[code].product.Price})
+		}
+	}
+
+	return units
+}
+
+func describeProducts(prodCodes []string) string {
+	if len(prodCodes) == 0 {
+		return "any product"
+	}
+
+	return strings.Join(prodCodes, " or ")
+}
+
+func copySet(set map[string]uint16) map[string]uint16 {
+	c := make(map[string]uint16, len(set))
+	for code, n := range set {
+		if n > 0 {
+			c[code] = n
+		}
+	}
+
+	return c
+}
+
+// Describes a set of products in product code order. Ie. "2 x ult_small + 1gb".
+func describeSet(set map[string]uint16) string {
+	codes := make([]string, 0, len(set))
+	for code := range set {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	s := make([]string, len(codes))
+	for i, code := range codes {
+		if set[code] == 1 {
+			s[i] = code
+		} else {
+			s[i] = fmt.Sprintf("%d x %s", set[code], code)
+		}
+	}
+
+	return strings.Join(s, " + ")
+}
+
+// Returns how many complete sets of products are in the cart.
+func completeSets(c Basket, set map[string]uint16) int {
+	if len(set) == 0 {
+		return 0
+	}
+
+	sets := -1
+	items := c.Items()
+	for code, n := range set {
+		count := 0
+		if v, ok := items[code]; ok {
+			count = int(v.count) / int(n)
+		}
+
+		if sets < 0 || count < sets {
+			sets = count
+		}
+	}
+
+	return sets
+}
+
+type completeSetCondition struct {
+	set map[string]uint16
+}
+
+func (c *completeSetCondition) String() string {
+	return fmt.Sprintf("at least one %s", describeSet(c.set))
+}
+
+func (c *completeSetCondition) Dependencies() Dependencies {
+	return setDependencies(c.set)
+}
+
+func (c *completeSetCondition) Satisfied(cart Basket) bool {
+	return completeSets(cart, c.set) > 0
+}
+
+type fixedPriceSetAction struct {
+	set   map[string]uint16
+	price PriceType
+}
+
+func (a *fixedPriceSetAction) String() string {
+	return fmt.Sprintf("%s for %d", describeSet(a.set), a.price)
+}
+
+func (a *fixedPriceSetAction) Dependencies() Dependencies {
+	return setDependencies(a.set)
+}
+
+func (a *fixedPriceSetAction) Apply(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	sets := completeSets(c, a.set)
+	if sets == 0 {
+		return 0, BundledProduct{}
+	}
+
+	var setPrice PriceType
+	items := c.Items()
+	for code, n := range a.set {
+		setPrice += PriceType(n) * items[code].product.Price
+	}
+
+	if setPrice <= a.price {
+		return 0, BundledProduct{}
+	}
+
+	return PriceType(sets) * (setPrice - a.price), BundledProduct{}
+}
+
+type minUnitsCondition struct {
+	prodCodes []string
+	n         uint16
+}
+
+func (c *minUnitsCondition) String() string {
+	return fmt.Sprintf("at least %d of %s", c.n, describeProducts(c.prodCodes))
+}
+
+func (c *minUnitsCondition) Dependencies() Dependencies {
+	return productDependencies(c.prodCodes...)
+}
+
+func (c *minUnitsCondition) Satisfied(cart Basket) bool {
+	units := 0
+	for code, v := range cart.Items() {
+		if len(c.prodCodes) == 0 || contains(c.prodCodes, code) {
+			units += int(v.count)
+		}
+	}
+
+	return units >= int(c.n)
+}
+
+type everyNthAction struct {
+	prodCodes   []string
+	n           uint16
+	discountPct int8
+}
+
+func (a *everyNthAction) String() string {
+	return fmt.Sprintf("%d%% off every %s of %s", a.discountPct, ordinal(a.n), describeProducts(a.prodCodes))
+}
+
+func (a *everyNthAction) Dependencies() Dependencies {
+	return productDependencies(a.prodCodes...)
+}
+
+func (a *everyNthAction) Apply(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	if a.n == 0 {
+		return 0, BundledProduct{}
+	}
+
+	units := expandUnits(c.Items(), a.prodCodes)
+	for i := int(a.n) - 1; i < len(units); i += int(a.n) {
+		discount += percentageOfPrice(units[i].price, a.discountPct)
+	}
+
+	return discount, BundledProduct{}
+}
+
+type cheapestFreeAction struct {
+	prodCodes []string
+}
+
+func (a *cheapestFreeAction) String() string {
+	return fmt.Sprintf("cheapest of %s free", describeProducts(a.prodCodes))
+}
+
+func (a *cheapestFreeAction) Dependencies() Dependencies {
+	return productDependencies(a.prodCodes...)
+}
+
+func (a *cheapestFreeAction) Apply(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	units := expandUnits(c.Items(), a.prodCodes)
+	if len(units) == 0 {
+		return 0, BundledProduct{}
+	}
+
+	return units[len(units)-1].price, BundledProduct{}
+}
+
+func ordinal(n uint16) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+
+	return fmt.Sprintf("%d%s", n, suffix)
+}
@@ -0,0 +1,73 @@
+package cart
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_ConditionalRule_GIVEN_AndCondition_WHEN_OnlyOneSatisfied_EXPECT_NoBundle(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rule := CreateConditionalRule(
+		And(MinQuantity("ult_medium", 1), HasPromoCode("DATA4FREE")),
+		BundleWithEvery("ult_medium", 1, "1gb", 1))
+	cart := CreateCart([]Rule{rule}, catalogue)
+
+	cart.Add(catalogue["ult_medium"])
+	actualDiscount, actualBundleProduct := rule.Evaluate(cart)
+	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, 0, BundledProduct{})
+
+	cart.AddPromoCode("DATA4FREE")
+	actualDiscount, actualBundleProduct = rule.Evaluate(cart)
+	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, 0, BundledProduct{"1gb", 1})
+}
+
+func Test_ConditionalRule_GIVEN_OrAndNotConditions_EXPECT_Combined(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rule := CreateConditionalRule(
+		And(Or(MinQuantity("ult_small", 2), MinQuantity("ult_large", 1)), Not(HasPromoCode("I<3AMAYSIM"))),
+		DiscountEachUnit("ult_small", 100))
+	cart := CreateCart([]Rule{rule}, catalogue)
+
+	cart.Add(catalogue["ult_small"])
+	actualDiscount, actualBundleProduct := rule.Evaluate(cart)
+	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, 0, BundledProduct{})
+
+	cart.Add(catalogue["ult_large"])
+	actualDiscount, actualBundleProduct = rule.Evaluate(cart)
+	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, 100, BundledProduct{})
+
+	cart.AddPromoCode("I<3AMAYSIM")
+	actualDiscount, actualBundleProduct = rule.Evaluate(cart)
+	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, 0, BundledProduct{})
+}
+
+func Test_ConditionalRule_GIVEN_CustomerCondition_EXPECT_AppliedToEligibleCustomers(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rule := CreateConditionalRule(ForCustomers(CustomersInSegment("business")), PercentOffItems(10))
+	cart := CreateCart([]Rule{rule}, catalogue)
+
+	cart.Add(catalogue["ult_large"])
+	actualDiscount, _ := rule.Evaluate(cart)
+	if actualDiscount != 0 {
+		t.Errorf("ActualDiscount: %v ExpectedDiscount: 0", actualDiscount)
+	}
+
+	cart.SetCustomer(Customer{Segment: "business"})
+	actualDiscount, _ = rule.Evaluate(cart)
+	if actualDiscount != 449 {
+		t.Errorf("ActualDiscount: %v ExpectedDiscount: 449", actualDiscount)
+	}
+}
+
+func Test_ConditionalRule_WHEN_Described_EXPECT_ReadableDescription(t *testing.T) {
+	rule := CreateConditionalRule(And(MinQuantity("ult_medium", 1), HasPromoCode("X")), BundleWithEvery("ult_medium", 1, "1gb", 1))
+	expected := "1 free 1gb with every 1 ult_medium when (at least 1 ult_medium and promo code X)"
+
+	if fmt.Sprint(rule) != expected {
+		t.Errorf("Description=%q, Expected=%q", fmt.Sprint(rule), expected)
+	}
+
+	if fmt.Sprint(CreateXForYRule("ult_small", 3, 2)) != "3 for 2 on ult_small" {
+		t.Errorf("Description=%q", fmt.Sprint(CreateXForYRule("ult_small", 3, 2)))
+	}
+}
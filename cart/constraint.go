@@ -0,0 +1,175 @@
+package cart
+
+import (
+	"fmt"
+	"math"
+)
+
+// The most units of a single product a cart can hold, as ProductCount.count is a uint16.
+const maxProductCount = math.MaxUint16
+
+type ConstraintKind int
+
+const (
+	MaxUnitsExceeded ConstraintKind = iota
+	MaxDistinctProductsExceeded
+	MaxLinesExceeded
+)
+
+func (k ConstraintKind) String() string {
+	switch k {
+	case MaxUnitsExceeded:
+		return "MaxUnitsExceeded"
+	case MaxDistinctProductsExceeded:
+		return "MaxDistinctProductsExceeded"
+	case MaxLinesExceeded:
+		return "MaxLinesExceeded"
+	}
+
+	return "Unknown"
+}
+
+// Returned when adding a product would break a purchase constraint.
+type ConstraintError struct {
+	Kind  ConstraintKind
+	Code  string // The product being added.
+	Limit int
+}
+
+func (e *ConstraintError) Error() string {
+	switch e.Kind {
+	case MaxUnitsExceeded:
+		return fmt.Sprintf("cart: cannot add %s, at most %d units allowed", e.Code, e.Limit)
+	case MaxDistinctProductsExceeded:
+		return fmt.Sprintf("cart: cannot add %s, at most %d different products allowed", e.Code, e.Limit)
+	case MaxLinesExceeded:
+		return fmt.Sprintf("cart: cannot add %s, at most %d lines allowed", e.Code, e.Limit)
+	}
+
+	return fmt.Sprintf("cart: cannot add %s", e.Code)
+}
+
+// Limits on what a cart may hold. Zero values mean no limit.
+type Constraints struct {
+	DefaultMaxUnits     int            // Max units of any one product.
+	MaxUnitsPerProduct  map[string]int // Product code => max units, overriding DefaultMaxUnits. Zero lifts DefaultMaxUnits for the product.
+	MaxDistinctProducts int
+	LineProducts        []string       // Product codes which each count as a line. Ie. SIMs.
+	MaxLines            map[string]int // Customer type => max lines, including existing services. "" applies to any other type.
+}
+
+func CreateDefaultConstraints() Constraints {
+	return Constraints{
+		DefaultMaxUnits: 50,
+		LineProducts:    CreateDefaultCategories()["sim"],
+		MaxLines: map[string]int{
+			"":         10,
+			"business": 200,
+		},
+	}
+}
+
+func WithConstraints(constraints Constraints) CartOption {
+	return func(c *defaultCart) {
+		c.constraints = constraints
+	}
+}
+
+// Sets the type of customer the cart belongs to. Ie. "consumer" or "business".
+// This is the Segment of the cart's Customer, and is set after every other
+// option, so it isn't overwritten by WithCustomer whatever their order.
+func WithCustomerType(customerType string) CartOption {
+	return func(c *defaultCart) {
+		c.customerType = customerType
+	}
+}
+
+func (cs Constraints) maxUnits(prodCode string) int {
+	max := maxProductCount
+	if limit, ok := cs.MaxUnitsPerProduct[prodCode]; ok {
+		if limit > 0 && limit < max {
+			max = limit
+		}
+	} else if cs.DefaultMaxUnits > 0 && cs.DefaultMaxUnits < max {
+		max = cs.DefaultMaxUnits
+	}
+
+	return max
+}
+
+func (cs Constraints) isLine(prodCode string) bool {
+	return contains(cs.LineProducts, prodCode)
+}
+
+func (cs Constraints) maxLines(customerType string) (int, bool) {
+	limit, ok := cs.MaxLines[customerType]
+	if !ok {
+		limit, ok = cs.MaxLines[""]
+	}
+
+	return limit, ok && limit > 0
+}
+
+// Counts the lines in the cart, and those the customer already has.
+func (c *defaultCart) lineCount() int {
+	lines := 0
+	for code, v := range c.products {
+		if c.constraints.isLine(code) {
+			lines += int(v.count)
+		}
+	}
+
+	for _, code := range c.customer.ExistingServices {
+		if c.constraints.isLine(code) {
+			lines++
+		}
+	}
+
+	return lines
+}
+
+// Returns an error if one more unit of the product would break a constraint.
+func (c *defaultCart) checkConstraints(prodCode string) error {
+	count := 0
+	if v, ok := c.products[prodCode]; ok {
+		count = int(v.count)
+	} else if max := c.constraints.MaxDistinctProducts; max > 0 && len(c.products) >= max {
+		return &ConstraintError{MaxDistinctProductsExceeded, prodCode, max}
+	}
+
+	if max := c.constraints.maxUnits(prodCode); count >= max {
+		return &ConstraintError{MaxUnitsExceeded, prodCode, max}
+	}
+
+	if c.constraints.isLine(prodCode) {
+		if max, ok := c.constraints.maxLines(c.customer.Segment); ok && c.lineCount() >= max {
+			return &ConstraintError{MaxLinesExceeded, prodCode, max}
+		}
+	}
+
+	return nil
+}
+
+// Returns how many more units of the product could be added to the cart.
+func (c *defaultCart) Allowance(prodCode string) int {
+	count := 0
+	if v, ok := c.products[prodCode]; ok {
+		count = int(v.count)
+	} else if max := c.constraints.MaxDistinctProducts; max > 0 && len(c.products) >= max {
+		return 0
+	}
+
+	remaining := c.constraints.maxUnits(prodCode) - count
+
+	if c.constraints.isLine(prodCode) {
+		if max, ok := c.constraints.maxLines(c.customer.Segment); ok && max-c.lineCount() < remaining {
+			remaining = max - c.lineCount()
+		}
+	}
+
+	if remaining < 0 {
+		return 0
+	}
+
+	return remaining
+}
@@ -0,0 +1,138 @@
+package cart
+
+import (
+	"errors"
+	"testing"
+)
+
+func checkConstraintError(t *testing.T, err error, expectedKind ConstraintKind) {
+	var ce *ConstraintError
+	if !errors.As(err, &ce) {
+		t.Errorf("Error=%v, Expected a ConstraintError", err)
+	} else if ce.Kind != expectedKind {
+		t.Errorf("ConstraintKind=%v, Expected=%v", ce.Kind, expectedKind)
+	}
+}
+
+func Test_Constraint_GIVEN_NoConstraints_WHEN_CountWouldWrap_EXPECT_MaxUnitsError(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(nil, catalogue, WithUndoLimit(0))
+
+	for i := 0; i < maxProductCount; i++ {
+		c.Add(catalogue["ult_large"])
+	}
+
+	checkConstraintError(t, c.Add(catalogue["ult_large"]), MaxUnitsExceeded)
+	checkCartContainsNProductsWithCode(t, c, "ult_large", maxProductCount)
+
+	if c.Allowance("ult_large") != 0 {
+		t.Errorf("Allowance=%d, Expected=0", c.Allowance("ult_large"))
+	}
+}
+
+func Test_Constraint_WHEN_MaxUnitsPerProductReached_EXPECT_ErrorAndCartUnchanged(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	constraints := Constraints{DefaultMaxUnits: 5, MaxUnitsPerProduct: map[string]int{"1gb": 2}}
+	c := CreateCart(CreateDefaultRules(), catalogue, WithConstraints(constraints))
+
+	c.Add(catalogue["1gb"])
+
+	if c.Allowance("1gb") != 1 || c.Allowance("ult_small") != 5 {
+		t.Errorf("Allowance(1gb)=%d Allowance(ult_small)=%d", c.Allowance("1gb"), c.Allowance("ult_small"))
+	}
+
+	if err := c.Add(catalogue["1gb"]); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	checkConstraintError(t, c.Add(catalogue["1gb"]), MaxUnitsExceeded)
+	checkCartContainsNProductsWithCode(t, c, "1gb", 2)
+
+	if c.Total() != 2*990 {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), 2*990)
+	}
+}
+
+func Test_Constraint_WHEN_MaxDistinctProductsReached_EXPECT_ErrorForNewProductOnly(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(nil, catalogue, WithConstraints(Constraints{MaxDistinctProducts: 2}))
+
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_medium"])
+
+	checkConstraintError(t, c.Add(catalogue["1gb"]), MaxDistinctProductsExceeded)
+
+	if err := c.Add(catalogue["ult_small"]); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if c.Allowance("1gb") != 0 {
+		t.Errorf("Allowance=%d, Expected=0", c.Allowance("1gb"))
+	}
+}
+
+func Test_Constraint_WHEN_MaxLinesForCustomerTypeReached_EXPECT_ErrorForLineProducts(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(nil, catalogue, WithConstraints(CreateDefaultConstraints()), WithCustomerType("consumer"))
+
+	for i := 0; i < 6; i++ {
+		c.Add(catalogue["ult_small"])
+	}
+	for i := 0; i < 4; i++ {
+		c.Add(catalogue["ult_large"])
+	}
+
+	if c.Allowance("ult_medium") != 0 || c.Allowance("1gb") != 50 {
+		t.Errorf("Allowance(ult_medium)=%d Allowance(1gb)=%d", c.Allowance("ult_medium"), c.Allowance("1gb"))
+	}
+
+	checkConstraintError(t, c.Add(catalogue["ult_medium"]), MaxLinesExceeded)
+
+	if err := c.Add(catalogue["1gb"]); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	b := CreateCart(nil, catalogue, WithConstraints(CreateDefaultConstraints()), WithCustomerType("business"))
+	for i := 0; i < 11; i++ {
+		if err := b.Add(catalogue["ult_small"]); err != nil {
+			t.Fatalf("Unexpected error for business customer: %v", err)
+		}
+	}
+}
+
+func Test_Constraint_WHEN_ZeroLimits_EXPECT_NoLimit(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	constraints := Constraints{
+		DefaultMaxUnits:    2,
+		MaxUnitsPerProduct: map[string]int{"ult_small": 0},
+		LineProducts:       CreateDefaultCategories()["sim"],
+		MaxLines:           map[string]int{"": 1, "business": 0},
+	}
+	c := CreateCart(nil, catalogue, WithConstraints(constraints), WithCustomerType("business"))
+
+	for i := 0; i < 3; i++ {
+		if err := c.Add(catalogue["ult_small"]); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if c.Allowance("ult_small") != maxProductCount-3 || c.Allowance("ult_medium") != 2 {
+		t.Errorf("Allowance(ult_small)=%d Allowance(ult_medium)=%d", c.Allowance("ult_small"), c.Allowance("ult_medium"))
+	}
+}
+
+func Test_Constraint_WHEN_CustomerTypeBeforeCustomer_EXPECT_CustomerTypeKept(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	customer := Customer{ID: "c1", Segment: "consumer"}
+
+	for _, opts := range [][]CartOption{
+		{WithCustomerType("business"), WithCustomer(customer)},
+		{WithCustomer(customer), WithCustomerType("business")},
+	} {
+		c := CreateCart(nil, catalogue, append(opts, WithConstraints(CreateDefaultConstraints()))...)
+
+		if c.Customer().Segment != "business" || c.Customer().ID != "c1" {
+			t.Errorf("Customer=%v, Expected business customer c1", c.Customer())
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package cart
+
+import (
+	"fmt"
+	"time"
+)
+
+// The customer a cart belongs to. The zero value is an anonymous new customer.
+type Customer struct {
+	ID               string
+	Tenure           time.Duration // How long they have been a customer. Zero for new customers.
+	Segment          string        // Ie. "consumer" or "business".
+	ExistingServices []string      // Product codes of services the customer already has.
+	Eligibilities    []string      // Verified eligibilities. Ie. "student" or "concession".
+}
+
+func (c Customer) IsNew() bool {
+	return c.Tenure == 0 && len(c.ExistingServices) == 0
+}
+
+func (c Customer) HasService(prodCode string) bool {
+	return contains(c.ExistingServices, prodCode)
+}
+
+func (c Customer) IsEligible(eligibility string) bool {
+	return contains(c.Eligibilities, eligibility)
+}
+
+func (c Customer) copy() Customer {
+	c.ExistingServices = append([]string(nil), c.ExistingServices...)
+	c.Eligibilities = append([]string(nil), c.Eligibilities...)
+	return c
+}
+
+func WithCustomer(customer Customer) CartOption {
+	return func(c *defaultCart) {
+		c.customer = customer.copy()
+	}
+}
+
+func (c *defaultCart) Customer() Customer {
+	return c.customer.copy()
+}
+
+// Changes the customer the cart belongs to. Ie. When they sign in part way
+// through shopping.
+func (c *defaultCart) SetCustomer(customer Customer) {
+	before := c.appliedRules
+	c.expireLocks()
+	c.customer = customer.copy()
+	c.engine.invalidate()
+	c.evaluateRules()
+	c.emit(before)
+}
+
+// Decides whether a customer is eligible for a rule.
+type CustomerFilter func(Customer) bool
+
+func NewCustomers(c Customer) bool {
+	return c.IsNew()
+}
+
+func ExistingCustomers(c Customer) bool {
+	return !c.IsNew()
+}
+
+func CustomersInSegment(segment string) CustomerFilter {
+	return func(c Customer) bool {
+		return c.Segment == segment
+	}
+}
+
+func CustomersWithEligibility(eligibility string) CustomerFilter {
+	return func(c Customer) bool {
+		return c.IsEligible(eligibility)
+	}
+}
+
+// Restricts a rule to customers accepted by the filter.
+func CreateCustomerRule(filter CustomerFilter, rule Rule) Rule {
+	return &customerRule{filter, rule}
+}
+
+type customerRule struct {
+	filter CustomerFilter
+	rule   Rule
+}
+
+func (r *customerRule) String() string {
+	return fmt.Sprintf("%v (eligible customers only)", r.rule)
+}
+
+func (r *customerRule) Dependencies() Dependencies {
+	return dependenciesOf(r.rule)
+}
+
+func (r *customerRule) unwrap() Rule {
+	return r.rule
+}
+
+func (r *customerRule) Explain(c Basket) (PromoReason, bool) {
+	if r.filter(c.Customer()) {
+		return PromoReason{}, false
+	}
+
+	return PromoReason{PromoConditionsNotMet, "not offered to this customer"}, true
+}
+
+func (r *customerRule) Evaluate(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	if !r.filter(c.Customer()) {
+		return 0, BundledProduct{}
+	}
+
+	return r.rule.Evaluate(c)
+}
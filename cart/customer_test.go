@@ -0,0 +1,80 @@
+package cart
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_CustomerRule_GIVEN_NewCustomersOnlyOffer_EXPECT_AppliedToNewCustomersOnly(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rules := []Rule{CreateCustomerRule(NewCustomers, CreatePromoRule("WELCOME", 50))}
+
+	newCustomer := CreateCart(rules, catalogue)
+	newCustomer.Add(catalogue["ult_small"])
+	newCustomer.AddPromoCode("WELCOME")
+
+	if newCustomer.Total() != 1245 {
+		t.Errorf("CartTotal=%d, Expected=1245", newCustomer.Total())
+	}
+
+	existing := CreateCart(rules, catalogue, WithCustomer(Customer{ID: "c1", Tenure: 365 * 24 * time.Hour}))
+	existing.Add(catalogue["ult_small"])
+	existing.AddPromoCode("WELCOME")
+
+	if existing.Total() != 2490 {
+		t.Errorf("CartTotal=%d, Expected=2490", existing.Total())
+	}
+}
+
+func Test_CustomerRule_GIVEN_ExistingCustomerSecondLineOffer_WHEN_CustomerSignsIn_EXPECT_RulesReEvaluated(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rules := []Rule{CreateCustomerRule(ExistingCustomers, CreateBulkDiscountRule("ult_small", 1, 500))}
+	c := CreateCart(rules, catalogue)
+
+	c.Add(catalogue["ult_small"])
+
+	if c.Total() != 2490 {
+		t.Errorf("CartTotal=%d, Expected=2490", c.Total())
+	}
+
+	c.SetCustomer(Customer{ID: "c1", ExistingServices: []string{"ult_medium"}})
+
+	if c.Total() != 1990 {
+		t.Errorf("CartTotal=%d, Expected=1990", c.Total())
+	}
+
+	if c.Customer().ID != "c1" || !c.Customer().HasService("ult_medium") {
+		t.Errorf("Customer=%v", c.Customer())
+	}
+}
+
+func Test_CustomerRule_GIVEN_SegmentAndEligibilityFilters_EXPECT_Filtered(t *testing.T) {
+	student := Customer{Segment: "consumer", Eligibilities: []string{"student"}}
+
+	if !CustomersInSegment("consumer")(student) || CustomersInSegment("business")(student) {
+		t.Errorf("Segment filter failed for %v", student)
+	}
+
+	if !CustomersWithEligibility("student")(student) || CustomersWithEligibility("concession")(student) {
+		t.Errorf("Eligibility filter failed for %v", student)
+	}
+}
+
+func Test_Customer_GIVEN_ExistingLines_WHEN_MaxLinesReached_EXPECT_Rejected(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	customer := Customer{ID: "c1", Segment: "consumer", ExistingServices: []string{"ult_small", "ult_large"}}
+	constraints := Constraints{LineProducts: CreateDefaultCategories()["sim"], MaxLines: map[string]int{"consumer": 3}}
+	c := CreateCart(nil, catalogue, WithCustomer(customer), WithConstraints(constraints))
+
+	if c.Allowance("ult_medium") != 1 {
+		t.Errorf("Allowance=%d, Expected=1", c.Allowance("ult_medium"))
+	}
+
+	c.Add(catalogue["ult_medium"])
+	checkConstraintError(t, c.Add(catalogue["ult_medium"]), MaxLinesExceeded)
+
+	o, _ := c.Checkout()
+	if o.Customer().ID != "c1" {
+		t.Errorf("OrderCustomer=%v", o.Customer())
+	}
+}
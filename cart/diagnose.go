@@ -0,0 +1,271 @@
+package cart
+
+import (
+	"fmt"
+	"time"
+)
+
+type PromoStatus int
+
+const (
+	PromoApplies           PromoStatus = iota
+	PromoUnknown                       // No rule depends on the code, nor could any rule apply it.
+	PromoNotStarted                    // The offer is scheduled to start later.
+	PromoExpired                       // The offer has ended.
+	PromoMinSpendNotMet                // The spend falls short of the offer's minimum.
+	PromoExcluded                      // The offer cannot be combined with another offer or promo code in the cart.
+	PromoUsageLimitReached             // The offer has been redeemed as often as it may be.
+	PromoConditionsNotMet              // Any other reason, including an offer with nothing to discount.
+	PromoUndetermined                  // No rule declares the code, and a rule which doesn't declare its codes may apply it to another cart.
+)
+
+func (s PromoStatus) String() string {
+	switch s {
+	case PromoApplies:
+		return "applies"
+	case PromoUnknown:
+		return "unknown code"
+	case PromoNotStarted:
+		return "not started"
+	case PromoExpired:
+		return "expired"
+	case PromoMinSpendNotMet:
+		return "minimum spend not met"
+	case PromoExcluded:
+		return "excluded by another offer"
+	case PromoUsageLimitReached:
+		return "usage limit reached"
+	case PromoConditionsNotMet:
+		return "conditions not met"
+	case PromoUndetermined:
+		return "undetermined"
+	}
+
+	return "unknown"
+}
+
+// Why an offer doesn't apply. Detail is a human readable explanation. Ie.
+// "spend 530 more".
+type PromoReason struct {
+	Status PromoStatus
+	Detail string
+}
+
+// Implemented by rules and conditions which can explain why they don't apply
+// to a basket. Returns false if they don't prevent the offer applying.
+// Conditions which don't implement it are reported as PromoConditionsNotMet.
+type Explainer interface {
+	Explain(Basket) (PromoReason, bool)
+}
+
+// Why a promo code entered in a cart does or doesn't apply.
+type PromoDiagnosis struct {
+	Code    string
+	Status  PromoStatus  // PromoApplies if any rule for the code applies, else the reason of the first rule.
+	Reasons []RuleReason // Why each rule for the code doesn't apply, in rule set order.
+}
+
+type RuleReason struct {
+	Index       int // Position of the rule in the rule set.
+	Description string
+	PromoReason
+}
+
+// Diagnoses each promo code in the cart, including those set aside, sorted by
+// code. A code is known if a rule declares it depends on it (see Dependent).
+// Rules which don't declare their dependencies, such as scripts, are checked by
+// pricing the cart without the code.
+func (c *defaultCart) PromoDiagnoses() []PromoDiagnosis {
+	codes := sortedCodes(c.codesHeld())
+	diagnoses := make([]PromoDiagnosis, 0, len(codes))
+	for _, code := range codes {
+		diagnoses = append(diagnoses, c.diagnosePromo(code))
+	}
+
+	return diagnoses
+}
+
+func (c *defaultCart) diagnosePromo(code string) PromoDiagnosis {
+	d := PromoDiagnosis{Code: code, Status: PromoUnknown}
+
+	var indexes, undeclared []int
+	for i, rule := range c.rules {
+		if ruleHasPromoCode(rule, code, c.promoPolicy.Normalize) {
+			indexes = append(indexes, i)
+		} else if !declaresDependencies(rule) {
+			undeclared = append(undeclared, i)
+		}
+	}
+
+	// A code set aside is not applied, though rules depending on its absence
+	// may apply.
+	_, setAside := c.setAside[code]
+	for _, ar := range c.appliedRules {
+		for _, i := range indexes {
+			if ar.Index == i && !setAside {
+				d.Status = PromoApplies
+				return d
+			}
+		}
+	}
+
+	if !setAside && len(undeclared) > 0 {
+		if c.appliesCode(code, undeclared) {
+			d.Status = PromoApplies
+			return d
+		}
+
+		if len(indexes) == 0 {
+			d.Status = PromoUndetermined
+			return d
+		}
+	}
+
+	now := c.now()
+	for _, i := range indexes {
+		rule := c.rules[i]
+		reason := PromoReason{}
+		if sa, ok := c.setAside[code]; ok {
+			reason = PromoReason{PromoExcluded, sa.String()}
+		} else {
+			reason = c.explainRule(i, rule, now)
+		}
+		d.Reasons = append(d.Reasons, RuleReason{i, fmt.Sprint(rule), reason})
+	}
+
+	if len(d.Reasons) > 0 {
+		d.Status = d.Reasons[0].Status
+	}
+
+	return d
+}
+
+// Whether the rule a chain of decorators ends in declares its dependencies.
+func declaresDependencies(rule Rule) bool {
+	declares := true
+	walkRule(rule, func(r Rule) bool {
+		if _, ok := r.(ruleWrapper); ok {
+			return false
+		}
+
+		_, declares = r.(Dependent)
+		return true
+	})
+
+	return declares
+}
+
+// Returns whether any of the rules applies differently to the cart without the
+// promo code.
+func (c *defaultCart) appliesCode(code string, indexes []int) bool {
+	var others []string
+	for _, held := range c.PromoCodes() {
+		if held != code {
+			others = append(others, held)
+		}
+	}
+
+	p := c.createUpsellProbe()
+	with := make(map[int]AppliedRule)
+	for _, ar := range p.baseline.AppliedRules {
+		with[ar.Index] = ar
+	}
+
+	without := make(map[int]AppliedRule)
+	for _, ar := range p.price(c.products, others).AppliedRules {
+		without[ar.Index] = ar
+	}
+
+	for _, i := range indexes {
+		if with[i].Discount != without[i].Discount || with[i].BundledProduct != without[i].BundledProduct {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Explains why a rule doesn't apply to the cart.
+func (c *defaultCart) explainRule(i int, rule Rule, now time.Time) PromoReason {
+	if !c.ruleAvailable(i, rule, now) {
+		return scheduleReason(rule, now)
+	}
+
+	view := c.ruleView(i)
+
+	var reason PromoReason
+	found := false
+	walkRule(rule, func(r Rule) bool {
+		if e, ok := r.(Explainer); ok {
+			reason, found = e.Explain(view)
+		}
+		return found
+	})
+
+	if found {
+		return reason
+	}
+
+	return PromoReason{PromoConditionsNotMet, "the offer gives no discount on this cart"}
+}
+
+// Returns the cart as a rule saw it when last evaluated.
+func (c *defaultCart) ruleView(i int) Basket {
+	b := c.basket()
+	traits := c.engine.traits[i]
+
+	var view Basket = b
+	if traits.countsBundles {
+		bundled := make(map[int]BundledProduct)
+		for _, ar := range c.appliedRules {
+			bundled[ar.Index] = ar.BundledProduct
+		}
+		view = c.engine.withBundledItems(b, bundled, i)
+	}
+
+	if traits.postOffer {
+		total := c.undiscountedTotal
+		for _, ar := range c.appliedRules {
+			if !c.engine.traits[ar.Index].postOffer {
+				total -= ar.Discount
+			}
+		}
+		view = &postOfferView{view, total}
+	}
+
+	return view
+}
+
+func scheduleReason(rule Rule, now time.Time) PromoReason {
+	reason := PromoReason{PromoExpired, "the offer is not active"}
+	walkRule(rule, func(r Rule) bool {
+		sr, ok := r.(*scheduledRule)
+		if !ok || sr.ActiveAt(now) {
+			return false
+		}
+
+		if !sr.start.IsZero() && now.Before(sr.start) {
+			reason = PromoReason{PromoNotStarted, fmt.Sprintf("the offer starts at %v", sr.start)}
+		} else if !sr.end.IsZero() && !now.Before(sr.end) {
+			reason = PromoReason{PromoExpired, fmt.Sprintf("the offer ended at %v", sr.end)}
+		}
+		return true
+	})
+
+	return reason
+}
+
+// Explains why a condition isn't satisfied, or returns false if it is.
+func explainCondition(cond Condition, b Basket) (PromoReason, bool) {
+	if cond.Satisfied(b) {
+		return PromoReason{}, false
+	}
+
+	if e, ok := cond.(Explainer); ok {
+		if reason, ok := e.Explain(b); ok {
+			return reason, true
+		}
+	}
+
+	return PromoReason{PromoConditionsNotMet, fmt.Sprintf("requires %v", cond)}, true
+}
@@ -0,0 +1,131 @@
+package cart
+
+import (
+	"testing"
+	"time"
+)
+
+func diagnosePromo(t *testing.T, c Cart, code string) PromoDiagnosis {
+	for _, d := range c.PromoDiagnoses() {
+		if d.Code == code {
+			return d
+		}
+	}
+
+	t.Fatalf("PromoDiagnoses=%v, Expected %s", c.PromoDiagnoses(), code)
+	return PromoDiagnosis{}
+}
+
+func Test_PromoDiagnoses_WHEN_Reasons_EXPECT_StatusPerCode(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	now := time.Now()
+	rules := []Rule{
+		CreatePromoRule("I<3AMAYSIM", 10),
+		CreateScheduledRule(CreatePromoRule("SUMMER", 10), time.Time{}, now.Add(-time.Hour)),
+		CreateScheduledRule(CreatePromoRule("WINTER", 10), now.Add(time.Hour), time.Time{}),
+		CreateConditionalRule(And(HasPromoCode("BIG"), MinSpend(10000, UndiscountedSpend)), FixedDiscount(1000)),
+		CreateConditionalRule(And(HasPromoCode("SIMS"), Not(HasPromoCode("I<3AMAYSIM"))), PercentOffItems(5)),
+		CreateUsageLimitedRule(CreatePromoRule("ONCE", 10), func(Customer) int { return 0 }),
+		CreateCustomerRule(NewCustomers, CreatePromoRule("WELCOME", 5)),
+	}
+
+	c := CreateCart(rules, catalogue, WithCustomer(Customer{ExistingServices: []string{"ult_small"}}))
+	c.Add(catalogue["ult_small"])
+	for _, code := range []string{"I<3AMAYSIM", "NOPE", "SUMMER", "WINTER", "BIG", "SIMS", "ONCE", "WELCOME"} {
+		c.AddPromoCode(code)
+	}
+
+	expected := map[string]PromoStatus{
+		"I<3AMAYSIM": PromoApplies,
+		"NOPE":       PromoUnknown,
+		"SUMMER":     PromoExpired,
+		"WINTER":     PromoNotStarted,
+		"BIG":        PromoMinSpendNotMet,
+		"SIMS":       PromoExcluded,
+		"ONCE":       PromoUsageLimitReached,
+		"WELCOME":    PromoConditionsNotMet,
+	}
+
+	diagnoses := c.PromoDiagnoses()
+	if len(diagnoses) != len(expected) {
+		t.Fatalf("PromoDiagnoses=%v", diagnoses)
+	}
+
+	for i, d := range diagnoses {
+		if i > 0 && d.Code < diagnoses[i-1].Code {
+			t.Errorf("PromoDiagnoses=%v, Expected sorted by code", diagnoses)
+		}
+
+		if d.Status != expected[d.Code] {
+			t.Errorf("%s: Status=%v, Expected=%v (%v)", d.Code, d.Status, expected[d.Code], d.Reasons)
+		}
+	}
+
+	if d := diagnosePromo(t, c, "BIG"); len(d.Reasons) != 1 || d.Reasons[0].Index != 3 || d.Reasons[0].Detail != "spend 7510 more before offers" {
+		t.Errorf("Reasons=%v", d.Reasons)
+	}
+
+	if d := diagnosePromo(t, c, "I<3AMAYSIM"); len(d.Reasons) != 0 {
+		t.Errorf("Reasons=%v", d.Reasons)
+	}
+}
+
+func Test_PromoDiagnoses_WHEN_NothingToDiscount_EXPECT_ConditionsNotMet(t *testing.T) {
+	c := CreateCart(CreateDefaultRules(), CreateDefaultCatalogue())
+	c.AddPromoCode("I<3AMAYSIM")
+
+	if d := diagnosePromo(t, c, "I<3AMAYSIM"); d.Status != PromoConditionsNotMet || d.Reasons[0].Detail != "the offer gives no discount on this cart" {
+		t.Errorf("PromoDiagnosis=%v", d)
+	}
+}
+
+func Test_UsageLimitedRule_WHEN_LimitReached_EXPECT_NoDiscount(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	remaining := 1
+	c := CreateCart([]Rule{CreateUsageLimitedRule(CreatePromoRule("ONCE", 10), func(Customer) int { return remaining })}, catalogue)
+	c.Add(catalogue["ult_medium"])
+	c.AddPromoCode("ONCE")
+
+	if c.Total() != 2691 {
+		t.Errorf("CartTotal=%d, Expected=2691", c.Total())
+	}
+
+	remaining = 0
+	c.Add(catalogue["ult_medium"])
+
+	if c.Total() != 2*2990 {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), 2*2990)
+	}
+}
+
+func Test_PromoDiagnoses_WHEN_ScriptUsesCode_EXPECT_NotUnknown(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	script, err := CreateScriptRule(`discount = has_promo("SCRIPT") && count("ult_medium") > 0 ? 100 : 0`, ScriptLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := CreateCart([]Rule{script}, catalogue)
+	c.Add(catalogue["ult_small"])
+	c.AddPromoCode("SCRIPT")
+
+	if d := diagnosePromo(t, c, "SCRIPT"); d.Status != PromoUndetermined {
+		t.Errorf("Without a medium: PromoDiagnosis=%v, Expected=%v", d, PromoUndetermined)
+	}
+
+	c.Add(catalogue["ult_medium"])
+	if d := diagnosePromo(t, c, "SCRIPT"); d.Status != PromoApplies {
+		t.Errorf("With a medium: PromoDiagnosis=%v, Expected=%v", d, PromoApplies)
+	}
+
+	if c.Total() != 2490+2990-100 {
+		t.Errorf("Total=%v, Expected=%v", c.Total(), 2490+2990-100)
+	}
+
+	c = CreateCart([]Rule{CreatePromoRule("I<3AMAYSIM", 10)}, catalogue)
+	c.Add(catalogue["ult_small"])
+	c.AddPromoCode("NOPE")
+	if d := diagnosePromo(t, c, "NOPE"); d.Status != PromoUnknown {
+		t.Errorf("Declared rules only: PromoDiagnosis=%v, Expected=%v", d, PromoUnknown)
+	}
+}
@@ -0,0 +1,124 @@
+package cart
+
+type EventType int
+
+const (
+	ItemAdded EventType = iota
+	ItemRemoved
+	PromoApplied
+	PromoRemoved
+	RuleTriggered
+	RuleReverted
+	Cleared
+	Undone
+	Redone
+	PromoRejected
+	PromoSetAside
+)
+
+func (t EventType) String() string {
+	switch t {
+	case ItemAdded:
+		return "ItemAdded"
+	case ItemRemoved:
+		return "ItemRemoved"
+	case PromoApplied:
+		return "PromoApplied"
+	case PromoRemoved:
+		return "PromoRemoved"
+	case RuleTriggered:
+		return "RuleTriggered"
+	case RuleReverted:
+		return "RuleReverted"
+	case Cleared:
+		return "Cleared"
+	case Undone:
+		return "Undone"
+	case Redone:
+		return "Redone"
+	case PromoRejected:
+		return "PromoRejected"
+	case PromoSetAside:
+		return "PromoSetAside"
+	}
+
+	return "Unknown"
+}
+
+type Event struct {
+	Type      EventType
+	Product   Product       // Set for ItemAdded and ItemRemoved.
+	LineID    string        // Set for ItemAdded and ItemRemoved.
+	PromoCode string        // Set for PromoApplied, PromoRemoved, PromoRejected and PromoSetAside. PromoRejected holds the code as entered.
+	Err       error         // Set for PromoRejected.
+	SetAside  SetAsidePromo // Set for PromoSetAside.
+	Rule      AppliedRule   // Set for RuleTriggered, and for RuleReverted holds what the rule contributed before reverting.
+}
+
+// Listeners are notified synchronously, in the order they were registered.
+//
+// Events are emitted once an interaction with the cart has completed and rules
+// have been re-evaluated, so a listener always observes a consistent cart.
+// For each interaction the cart emits:
+//  1. The event for the interaction itself (ItemAdded, ItemRemoved,
+//     PromoApplied, PromoRemoved, Cleared, Undone or Redone), if it changed
+//     the cart, or PromoRejected if a promo code was rejected. A promo code
+//     added is only PromoApplied if it is applied rather than set aside.
+//  2. PromoSetAside for each code set aside in favour of codes which give a
+//     lower total, and PromoApplied for each code no longer set aside, in code
+//     order. See PromoPolicy.SelectBest.
+//  3. RuleReverted for each rule which no longer applies, in rule set order.
+//  4. RuleTriggered for each rule which now applies, or whose discount or
+//     bundled product changed, in rule set order.
+//
+// Listeners must not modify the cart.
+type Listener interface {
+	CartEvent(Event)
+}
+
+type ListenerFunc func(Event)
+
+func (f ListenerFunc) CartEvent(e Event) {
+	f(e)
+}
+
+func WithListener(l Listener) CartOption {
+	return func(c *defaultCart) {
+		c.listeners = append(c.listeners, l)
+	}
+}
+
+// Emits the given events followed by the changes in applied rules since before.
+func (c *defaultCart) emit(before []AppliedRule, events ...Event) {
+	events = append(events, c.promoEvents...)
+	c.promoEvents = nil
+
+	if len(c.listeners) == 0 {
+		return
+	}
+
+	after := make(map[int]AppliedRule)
+	for _, ar := range c.appliedRules {
+		after[ar.Index] = ar
+	}
+
+	prior := make(map[int]AppliedRule)
+	for _, ar := range before {
+		prior[ar.Index] = ar
+		if _, ok := after[ar.Index]; !ok {
+			events = append(events, Event{Type: RuleReverted, Rule: ar})
+		}
+	}
+
+	for _, ar := range c.appliedRules {
+		if p, ok := prior[ar.Index]; !ok || p.Discount != ar.Discount || p.BundledProduct != ar.BundledProduct {
+			events = append(events, Event{Type: RuleTriggered, Rule: ar})
+		}
+	}
+
+	for _, e := range events {
+		for _, l := range c.listeners {
+			l.CartEvent(e)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package cart
+
+import (
+	"reflect"
+	"testing"
+)
+
+type eventRecorder struct {
+	events []Event
+}
+
+func (r *eventRecorder) CartEvent(e Event) {
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) types() []EventType {
+	types := []EventType{}
+	for _, e := range r.events {
+		types = append(types, e.Type)
+	}
+
+	return types
+}
+
+func (r *eventRecorder) reset() {
+	r.events = nil
+}
+
+func Test_Event_WHEN_OfferTriggeredAndReverted_EXPECT_EventsInOrder(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	recorder := &eventRecorder{}
+	c := CreateCart(CreateDefaultRules(), catalogue, WithListener(recorder))
+
+	c.Add(catalogue["ult_medium"])
+
+	expected := []EventType{ItemAdded, RuleTriggered}
+	if !reflect.DeepEqual(recorder.types(), expected) {
+		t.Errorf("Events=%v, Expected=%v", recorder.types(), expected)
+	}
+
+	if recorder.events[1].Rule.BundledProduct != (BundledProduct{"1gb", 1}) {
+		t.Errorf("TriggeredRule=%v", recorder.events[1].Rule)
+	}
+
+	recorder.reset()
+	c.Remove(catalogue["ult_medium"])
+
+	expected = []EventType{ItemRemoved, RuleReverted}
+	if !reflect.DeepEqual(recorder.types(), expected) {
+		t.Errorf("Events=%v, Expected=%v", recorder.types(), expected)
+	}
+}
+
+func Test_Event_WHEN_PromoCodeAddedTwiceAndRemoved_EXPECT_SinglePromoEvents(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	recorder := &eventRecorder{}
+	c := CreateCart(CreateDefaultRules(), catalogue, WithListener(recorder))
+
+	c.Add(catalogue["1gb"])
+	recorder.reset()
+
+	c.AddPromoCode("I<3AMAYSIM")
+	c.AddPromoCode("I<3AMAYSIM")
+	c.RemovePromoCode("I<3AMAYSIM")
+	c.RemovePromoCode("I<3AMAYSIM")
+
+	expected := []EventType{PromoApplied, RuleTriggered, PromoRemoved, RuleReverted}
+	if !reflect.DeepEqual(recorder.types(), expected) {
+		t.Errorf("Events=%v, Expected=%v", recorder.types(), expected)
+	}
+}
+
+func Test_Event_WHEN_Cleared_EXPECT_ClearedThenRevertedInRuleOrder(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	recorder := &eventRecorder{}
+	c := CreateCart(CreateDefaultRules(), catalogue, WithListener(recorder))
+
+	for i := 0; i < 3; i++ {
+		c.Add(catalogue["ult_small"])
+	}
+	c.Add(catalogue["ult_medium"])
+	recorder.reset()
+
+	c.Clear()
+
+	expected := []EventType{Cleared, RuleReverted, RuleReverted}
+	if !reflect.DeepEqual(recorder.types(), expected) {
+		t.Fatalf("Events=%v, Expected=%v", recorder.types(), expected)
+	}
+
+	if recorder.events[1].Rule.Index != 0 || recorder.events[2].Rule.Index != 2 {
+		t.Errorf("Reverted rules out of order: %v", recorder.events[1:])
+	}
+}
+
+func Test_Event_GIVEN_MultipleListeners_EXPECT_RegistrationOrder(t *testing.T) {
+	order := []string{}
+	c := CreateCart(nil, CreateDefaultCatalogue(),
+		WithListener(ListenerFunc(func(Event) { order = append(order, "first") })),
+		WithListener(ListenerFunc(func(Event) { order = append(order, "second") })))
+
+	c.AddPromoCode("1337")
+
+	if !reflect.DeepEqual(order, []string{"first", "second"}) {
+		t.Errorf("Order=%v", order)
+	}
+}
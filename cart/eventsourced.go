@@ -0,0 +1,372 @@
+package cart
+
+import (
+	"time"
+)
+
+type OperationType int
+
+const (
+	AddOperation OperationType = iota
+	RemoveOperation
+	AddPromoCodeOperation
+	RemovePromoCodeOperation
+	ClearOperation
+	UndoOperation
+	RedoOperation
+	RemoveLineOperation
+	SetLineAttributeOperation
+	SetCustomerOperation
+	SelectGiftOperation
+	AcceptBundleOperation
+	DeclineBundleOperation
+	RepriceOperation
+)
+
+func (t OperationType) String() string {
+	switch t {
+	case AddOperation:
+		return "Add"
+	case RemoveOperation:
+		return "Remove"
+	case AddPromoCodeOperation:
+		return "AddPromoCode"
+	case RemovePromoCodeOperation:
+		return "RemovePromoCode"
+	case ClearOperation:
+		return "Clear"
+	case UndoOperation:
+		return "Undo"
+	case RedoOperation:
+		return "Redo"
+	case RemoveLineOperation:
+		return "RemoveLine"
+	case SetLineAttributeOperation:
+		return "SetLineAttribute"
+	case SetCustomerOperation:
+		return "SetCustomer"
+	case SelectGiftOperation:
+		return "SelectGift"
+	case AcceptBundleOperation:
+		return "AcceptBundle"
+	case DeclineBundleOperation:
+		return "DeclineBundle"
+	case RepriceOperation:
+		return "Reprice"
+	}
+
+	return "Unknown"
+}
+
+// A single interaction with a cart, as recorded in its log.
+type Operation struct {
+	Seq        int // Position in the log, starting at 1.
+	At         time.Time
+	Type       OperationType
+	Product    Product           // Set for Add and Remove.
+	PromoCode  string            // Set for AddPromoCode and RemovePromoCode.
+	LineID     string            // Set for RemoveLine and SetLineAttribute.
+	Attributes map[string]string // Set for Add of a line with attributes, and SetLineAttribute.
+	Customer   Customer          // Set for SetCustomer.
+	RuleID     string            // Set for SelectGift.
+	Gift       string            // Set for SelectGift.
+	Bundle     BundleOffer       // Set for AcceptBundle and DeclineBundle.
+	Prices     []PriceChange     // Set for Reprice, recorded when expired price locks changed prices.
+}
+
+// A cart which records every interaction in an append-only log, allowing its
+// state at any earlier point to be rebuilt.
+type EventSourcedCart interface {
+	Cart
+	Log() []Operation
+	// Rebuilds the cart as it was after the first n operations, pricing
+	// products from the given catalogue and evaluating the given rules.
+	Rebuild(n int, rules []Rule, catalogue Catalogue) Cart
+	// Rebuilds the cart as it was at time t, using the prices recorded in the
+	// log and the rules the cart was created with.
+	StateAt(t time.Time) Cart
+	// Returns the change in total between the states after the first from
+	// and the first to operations.
+	DiffTotals(from, to int) PriceType
+}
+
+func CreateEventSourcedCart(rules []Rule, catalogue Catalogue, opts ...CartOption) EventSourcedCart {
+	c := CreateCart(rules, catalogue, opts...).(*defaultCart)
+
+	es := &eventSourcedCart{
+		Cart:      c,
+		cart:      c,
+		now:       c.now,
+		rules:     rules,
+		catalogue: catalogue,
+		opts:      opts,
+	}
+
+	// Prices change when locks expire, before the interaction which expired
+	// them is recorded.
+	onReprice := c.onReprice
+	c.onReprice = func(n RepriceNotice) {
+		if len(n.PriceChanges) > 0 {
+			es.record(Operation{Type: RepriceOperation, Prices: append([]PriceChange{}, n.PriceChanges...)})
+		}
+		if onReprice != nil {
+			onReprice(n)
+		}
+	}
+
+	return es
+}
+
+// Replays a log against the given rules and catalogue. Products found in the
+// catalogue are priced from it, otherwise the recorded product is used.
+func Replay(log []Operation, rules []Rule, catalogue Catalogue, opts ...CartOption) Cart {
+	return replay(log, rules, catalogue, true, opts)
+}
+
+func replay(log []Operation, rules []Rule, catalogue Catalogue, reprice bool, opts []CartOption) Cart {
+	var clock time.Time
+	if len(log) > 0 {
+		clock = log[0].At
+	}
+
+	// Unless repricing, products are priced as the log recorded them, both
+	// when added and when expired locks changed their price.
+	if !reprice {
+		recorded := make(Catalogue, len(catalogue))
+		for code, p := range catalogue {
+			recorded[code] = p
+		}
+		catalogue = recorded
+	}
+
+	opts = append(append([]CartOption{}, opts...), WithClock(func() time.Time { return clock }))
+	c := CreateCart(rules, catalogue, opts...).(*defaultCart)
+
+	// Replaying must not notify anyone of events which have already happened.
+	c.listeners = nil
+	c.onReprice = nil
+
+	for _, op := range log {
+		clock = op.At
+
+		p := op.Product
+		if current, ok := catalogue[p.Code]; ok && reprice {
+			p = current
+		} else if op.Type == AddOperation && !reprice {
+			catalogue[p.Code] = p
+		}
+
+		switch op.Type {
+		case AddOperation:
+			c.AddLine(p, op.Attributes)
+		case RemoveOperation:
+			c.Remove(p)
+		case AddPromoCodeOperation:
+			c.AddPromoCode(op.PromoCode)
+		case RemovePromoCodeOperation:
+			c.RemovePromoCode(op.PromoCode)
+		case ClearOperation:
+			c.Clear()
+		case UndoOperation:
+			c.Undo()
+		case RedoOperation:
+			c.Redo()
+		case RemoveLineOperation:
+			c.RemoveLine(op.LineID)
+		case SetLineAttributeOperation:
+			for k, v := range op.Attributes {
+				c.SetLineAttribute(op.LineID, k, v)
+			}
+		case SetCustomerOperation:
+			c.SetCustomer(op.Customer)
+		case SelectGiftOperation:
+			c.SelectGift(op.RuleID, op.Gift)
+		case AcceptBundleOperation:
+			c.AcceptBundle(op.Bundle.RuleID, op.Bundle.ProdCode)
+		case DeclineBundleOperation:
+			c.DeclineBundle(op.Bundle.RuleID, op.Bundle.ProdCode)
+		case RepriceOperation:
+			if reprice {
+				break
+			}
+			for _, change := range op.Prices {
+				p, ok := catalogue[change.Code]
+				if !ok {
+					p = Product{Code: change.Code}
+				}
+				p.Price = change.NewPrice
+				catalogue[change.Code] = p
+			}
+			c.expireLocks()
+		}
+	}
+
+	return c
+}
+
+type eventSourcedCart struct {
+	Cart
+	cart      *defaultCart // The cart Cart holds.
+	now       func() time.Time
+	rules     []Rule
+	catalogue Catalogue
+	opts      []CartOption
+	log       []Operation
+}
+
+func (c *eventSourcedCart) record(op Operation) {
+	op.Seq = len(c.log) + 1
+	op.At = c.now()
+	c.log = append(c.log, op)
+}
+
+func (c *eventSourcedCart) Add(p Product) error {
+	_, err := c.AddLine(p, nil)
+	return err
+}
+
+func (c *eventSourcedCart) AddLine(p Product, attributes map[string]string) (string, error) {
+	id, err := c.Cart.AddLine(p, attributes)
+	if err != nil {
+		return "", err
+	}
+
+	c.record(Operation{Type: AddOperation, Product: p, Attributes: copyAttributes(attributes)})
+	return id, nil
+}
+
+func (c *eventSourcedCart) RemoveLine(id string) error {
+	if err := c.Cart.RemoveLine(id); err != nil {
+		return err
+	}
+
+	c.record(Operation{Type: RemoveLineOperation, LineID: id})
+	return nil
+}
+
+func (c *eventSourcedCart) SetLineAttribute(id, key, value string) error {
+	if err := c.Cart.SetLineAttribute(id, key, value); err != nil {
+		return err
+	}
+
+	c.record(Operation{Type: SetLineAttributeOperation, LineID: id, Attributes: map[string]string{key: value}})
+	return nil
+}
+
+// Operations are recorded once the cart has performed them, so repricing by
+// a lock which expired during one is recorded before it.
+func (c *eventSourcedCart) Remove(p Product) {
+	_, held := c.cart.products[p.Code]
+	c.Cart.Remove(p)
+
+	if held {
+		c.record(Operation{Type: RemoveOperation, Product: p})
+	}
+}
+
+func (c *eventSourcedCart) AddPromoCode(code string) error {
+	if err := c.Cart.AddPromoCode(code); err != nil {
+		return err
+	}
+
+	c.record(Operation{Type: AddPromoCodeOperation, PromoCode: code})
+	return nil
+}
+
+func (c *eventSourcedCart) RemovePromoCode(code string) {
+	held := c.cart.promoCodes[c.cart.promoPolicy.Normalize.apply(code)]
+	c.Cart.RemovePromoCode(code)
+
+	if held {
+		c.record(Operation{Type: RemovePromoCodeOperation, PromoCode: code})
+	}
+}
+
+func (c *eventSourcedCart) Clear() {
+	c.Cart.Clear()
+	c.record(Operation{Type: ClearOperation})
+}
+
+func (c *eventSourcedCart) Undo() bool {
+	if !c.Cart.Undo() {
+		return false
+	}
+
+	c.record(Operation{Type: UndoOperation})
+	return true
+}
+
+func (c *eventSourcedCart) Redo() bool {
+	if !c.Cart.Redo() {
+		return false
+	}
+
+	c.record(Operation{Type: RedoOperation})
+	return true
+}
+
+func (c *eventSourcedCart) SetCustomer(customer Customer) {
+	c.Cart.SetCustomer(customer)
+	c.record(Operation{Type: SetCustomerOperation, Customer: customer.copy()})
+}
+
+func (c *eventSourcedCart) SelectGift(ruleID, prodCode string) error {
+	if err := c.Cart.SelectGift(ruleID, prodCode); err != nil {
+		return err
+	}
+
+	c.record(Operation{Type: SelectGiftOperation, RuleID: ruleID, Gift: prodCode})
+	return nil
+}
+
+func (c *eventSourcedCart) AcceptBundle(ruleID, prodCode string) error {
+	if err := c.Cart.AcceptBundle(ruleID, prodCode); err != nil {
+		return err
+	}
+
+	c.record(Operation{Type: AcceptBundleOperation, Bundle: BundleOffer{ruleID, prodCode}})
+	return nil
+}
+
+func (c *eventSourcedCart) DeclineBundle(ruleID, prodCode string) error {
+	if err := c.Cart.DeclineBundle(ruleID, prodCode); err != nil {
+		return err
+	}
+
+	c.record(Operation{Type: DeclineBundleOperation, Bundle: BundleOffer{ruleID, prodCode}})
+	return nil
+}
+
+func (c *eventSourcedCart) Log() []Operation {
+	return append([]Operation{}, c.log...)
+}
+
+func (c *eventSourcedCart) prefix(n int) []Operation {
+	if n < 0 {
+		n = 0
+	} else if n > len(c.log) {
+		n = len(c.log)
+	}
+
+	return c.log[:n]
+}
+
+func (c *eventSourcedCart) Rebuild(n int, rules []Rule, catalogue Catalogue) Cart {
+	return replay(c.prefix(n), rules, catalogue, true, c.opts)
+}
+
+func (c *eventSourcedCart) StateAt(t time.Time) Cart {
+	n := 0
+	for n < len(c.log) && !c.log[n].At.After(t) {
+		n++
+	}
+
+	return replay(c.prefix(n), c.rules, c.catalogue, false, c.opts)
+}
+
+func (c *eventSourcedCart) DiffTotals(from, to int) PriceType {
+	fromCart := replay(c.prefix(from), c.rules, c.catalogue, false, c.opts)
+	toCart := replay(c.prefix(to), c.rules, c.catalogue, false, c.opts)
+
+	return toCart.Total() - fromCart.Total()
+}
@@ -0,0 +1,194 @@
+package cart
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_EventSourcedCart_WHEN_Interacted_EXPECT_OperationsLoggedInOrder(t *testing.T) {
+	clock := createTestClock()
+	catalogue := CreateDefaultCatalogue()
+	c := CreateEventSourcedCart(CreateDefaultRules(), catalogue, WithClock(clock.now))
+
+	c.Add(catalogue["ult_small"])
+	clock.advance(time.Minute)
+	c.AddPromoCode("I<3AMAYSIM")
+	c.Remove(catalogue["ult_small"])
+	c.RemovePromoCode("I<3AMAYSIM")
+	c.Clear()
+
+	log := c.Log()
+	expected := []OperationType{AddOperation, AddPromoCodeOperation, RemoveOperation, RemovePromoCodeOperation, ClearOperation}
+	if len(log) != len(expected) {
+		t.Fatalf("Log=%v", log)
+	}
+
+	for i, op := range log {
+		if op.Type != expected[i] || op.Seq != i+1 {
+			t.Errorf("Operation %d=%v, Expected type %v", i, op, expected[i])
+		}
+	}
+
+	if !log[1].At.Equal(clock.t) || log[1].PromoCode != "I<3AMAYSIM" || log[0].Product.Code != "ult_small" {
+		t.Errorf("Operations recorded incorrectly: %v", log)
+	}
+}
+
+func Test_EventSourcedCart_WHEN_StateAtEarlierTime_EXPECT_CartAsItWasThen(t *testing.T) {
+	clock := createTestClock()
+	catalogue := CreateDefaultCatalogue()
+	c := CreateEventSourcedCart(CreateDefaultRules(), catalogue, WithClock(clock.now))
+
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+	clock.advance(time.Minute)
+	checkpoint := clock.t
+	c.Add(catalogue["ult_small"])
+	clock.advance(time.Minute)
+	c.Add(catalogue["ult_large"])
+
+	s := c.StateAt(checkpoint)
+	checkCartContainsNProductsWithCode(t, s, "ult_small", 3)
+	checkCartContainsNProductsWithCode(t, s, "ult_large", 0)
+
+	if s.Total() != 2*2490 {
+		t.Errorf("CartTotal=%d, Expected=%d", s.Total(), 2*2490)
+	}
+
+	if c.DiffTotals(2, 3) != 0 || c.DiffTotals(3, 4) != 4490 {
+		t.Errorf("DiffTotals(2,3)=%d DiffTotals(3,4)=%d", c.DiffTotals(2, 3), c.DiffTotals(3, 4))
+	}
+}
+
+func Test_EventSourcedCart_WHEN_RebuiltAgainstNewRulesAndCatalogue_EXPECT_Repriced(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateEventSourcedCart(CreateDefaultRules(), catalogue)
+
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+
+	newCatalogue := CreateDefaultCatalogue()
+	newCatalogue["ult_small"] = Product{"ult_small", "Unlimited 1GB", 1000}
+	r := c.Rebuild(len(c.Log()), []Rule{CreateXForYRule("ult_small", 2, 1)}, newCatalogue)
+
+	if r.Total() != 1000 {
+		t.Errorf("CartTotal=%d, Expected=1000", r.Total())
+	}
+
+	if c.Total() != 2*2490 {
+		t.Errorf("Rebuilding changed the live cart. CartTotal=%d", c.Total())
+	}
+}
+
+func Test_EventSourcedCart_WHEN_RebuiltWithRulesReordered_EXPECT_BundleChoiceKept(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	medium := CreateBundleModeRule("medium-data", CreateBundleRule("ult_medium", 1, "1gb", 1), DeclinableBundle)
+	large := CreateBundleModeRule("large-data", CreateBundleRule("ult_large", 1, "1gb", 2), DeclinableBundle)
+	c := CreateEventSourcedCart([]Rule{medium, large}, catalogue)
+
+	c.Add(catalogue["ult_medium"])
+	c.Add(catalogue["ult_large"])
+	c.DeclineBundle("medium-data", "1gb")
+
+	r := c.Rebuild(len(c.Log()), []Rule{large, medium}, catalogue)
+	checkCartContainsNBundledProductsWithCode(t, r, "1gb", 2)
+	if v := r.SuggestedBundles()["1gb"]; v == nil || v.count != 1 {
+		t.Errorf("SuggestedBundles=%v", r.SuggestedBundles())
+	}
+}
+
+func Test_EventSourcedCart_WHEN_Replayed_EXPECT_ListenersNotNotified(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	recorder := &eventRecorder{}
+	c := CreateEventSourcedCart(CreateDefaultRules(), catalogue, WithListener(recorder))
+
+	c.Add(catalogue["ult_medium"])
+	recorder.reset()
+
+	c.StateAt(time.Now())
+
+	if len(recorder.events) != 0 {
+		t.Errorf("Events=%v, Expected none", recorder.events)
+	}
+}
+
+func Test_EventSourcedCart_WHEN_LockExpiryRepriced_EXPECT_StateAtUsesRecordedPrices(t *testing.T) {
+	clock := createTestClock()
+	catalogue := CreateDefaultCatalogue()
+	c := CreateEventSourcedCart(nil, catalogue, WithClock(clock.now), WithPriceLock(10*time.Minute))
+
+	c.Add(catalogue["ult_medium"])
+	clock.advance(15 * time.Minute)
+	repriced := clock.t
+	catalogue["ult_medium"] = Product{"ult_medium", "Unlimited 2GB", 3990}
+	c.Add(catalogue["1gb"])
+
+	// Prices changed since aren't seen by earlier states.
+	clock.advance(15 * time.Minute)
+	catalogue["ult_medium"] = Product{"ult_medium", "Unlimited 2GB", 4990}
+
+	log := c.Log()
+	if len(log) != 3 || log[1].Type != RepriceOperation || len(log[1].Prices) != 1 || log[1].Prices[0] != (PriceChange{"ult_medium", 2990, 3990}) {
+		t.Fatalf("Log=%v", log)
+	}
+
+	if s := c.StateAt(repriced.Add(-time.Minute)); s.Total() != 2990 {
+		t.Errorf("CartTotal=%d, Expected=2990", s.Total())
+	}
+
+	if s := c.StateAt(repriced); s.Total() != 3990+990 {
+		t.Errorf("CartTotal=%d, Expected=%d", s.Total(), 3990+990)
+	}
+
+	if c.DiffTotals(1, 2) != 1000 {
+		t.Errorf("DiffTotals(1,2)=%d, Expected=1000", c.DiffTotals(1, 2))
+	}
+}
+
+func Test_EventSourcedCart_WHEN_LockExpiresDuringOperation_EXPECT_RepriceLoggedBeforeIt(t *testing.T) {
+	for _, tc := range []struct {
+		op       OperationType
+		interact func(c EventSourcedCart, catalogue Catalogue)
+	}{
+		{RemoveOperation, func(c EventSourcedCart, catalogue Catalogue) { c.Remove(catalogue["ult_medium"]) }},
+		{SetCustomerOperation, func(c EventSourcedCart, catalogue Catalogue) { c.SetCustomer(Customer{ID: "c1"}) }},
+		{ClearOperation, func(c EventSourcedCart, catalogue Catalogue) { c.Checkout(); c.Clear() }},
+	} {
+		clock := createTestClock()
+		catalogue := CreateDefaultCatalogue()
+		c := CreateEventSourcedCart(nil, catalogue, WithClock(clock.now), WithPriceLock(10*time.Minute))
+
+		c.Add(catalogue["ult_medium"])
+		c.Add(catalogue["ult_medium"])
+		clock.advance(15 * time.Minute)
+		catalogue["ult_medium"] = Product{"ult_medium", "Unlimited 2GB", 3990}
+		tc.interact(c, catalogue)
+
+		log := c.Log()
+		if n := len(log); n != 4 || log[2].Type != RepriceOperation || log[3].Type != tc.op {
+			t.Errorf("%v: Log=%v", tc.op, log)
+			continue
+		}
+
+		if s := c.StateAt(clock.t); s.Total() != c.Total() {
+			t.Errorf("%v: CartTotal=%d, Expected=%d", tc.op, s.Total(), c.Total())
+		}
+
+		// Repriced before the operation.
+		if s := c.Rebuild(3, nil, catalogue); s.Total() != 2*3990 || c.DiffTotals(2, 3) != 2000 {
+			t.Errorf("%v: CartTotal=%d DiffTotals(2,3)=%d", tc.op, s.Total(), c.DiffTotals(2, 3))
+		}
+	}
+}
+
+func Test_EventSourcedCart_WHEN_RemoveChangesNothing_EXPECT_NotLogged(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateEventSourcedCart(CreateDefaultRules(), catalogue)
+
+	c.Remove(catalogue["ult_small"])
+	c.RemovePromoCode("I<3AMAYSIM")
+
+	if len(c.Log()) != 0 {
+		t.Errorf("Log=%v, Expected none", c.Log())
+	}
+}
@@ -0,0 +1,159 @@
+package cart
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnknownGiftRule = errors.New("cart: no gift rule with that ID")
+	ErrIneligibleGift  = errors.New("cart: product is not one of the gifts offered")
+	ErrGiftNotOffered  = errors.New("cart: gift rule does not currently apply")
+)
+
+// A bundle rule where the customer chooses which of several products they get
+// for free. Ie. Buy ult_large, choose a free 1gb or a free ult_small.
+type GiftRule interface {
+	Rule
+	ID() string
+	Gifts() []string
+	DefaultGift() string
+}
+
+// Creates a rule bundling itemsToGet of a gift with every itemsToBuy of a
+// product. The gift is the one selected on the cart via SelectGift, or
+// defaultGift if none has been. An empty defaultGift uses the first gift.
+func CreateGiftRule(id string, buyProdCode string, itemsToBuy uint16, gifts []string, itemsToGet uint16, defaultGift string) GiftRule {
+	if defaultGift == "" && len(gifts) > 0 {
+		defaultGift = gifts[0]
+	}
+
+	return &giftRule{id, buyProdCode, itemsToBuy, append([]string(nil), gifts...), itemsToGet, defaultGift}
+}
+
+type giftRule struct {
+	id          string
+	buyProdCode string
+	itemsToBuy  uint16
+	gifts       []string
+	itemsToGet  uint16
+	defaultGift string
+}
+
+func (r *giftRule) ID() string {
+	return r.id
+}
+
+func (r *giftRule) Gifts() []string {
+	return append([]string(nil), r.gifts...)
+}
+
+func (r *giftRule) DefaultGift() string {
+	return r.defaultGift
+}
+
+func (r *giftRule) String() string {
+	return fmt.Sprintf("%d free %s with every %d %s", r.itemsToGet, strings.Join(r.gifts, " or "), r.itemsToBuy, r.buyProdCode)
+}
+
+func (r *giftRule) Dependencies() Dependencies {
+	return productDependencies(r.buyProdCode)
+}
+
+func (r *giftRule) Evaluate(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	if !MinQuantity(r.buyProdCode, r.itemsToBuy).Satisfied(c) {
+		return 0, BundledProduct{}
+	}
+
+	gift := c.SelectedGift(r.id)
+	if !contains(r.gifts, gift) {
+		gift = r.defaultGift
+	}
+
+	return BundleWithEvery(r.buyProdCode, r.itemsToBuy, gift, r.itemsToGet).Apply(chargedItems(c))
+}
+
+// Returns the gift rule a rule is, or decorates.
+func asGiftRule(rule Rule) (GiftRule, bool) {
+	var gr GiftRule
+	ok := walkRule(rule, func(r Rule) bool {
+		gr, _ = r.(GiftRule)
+		return gr != nil
+	})
+
+	return gr, ok
+}
+
+// Returns the index in the rule set of the rule with the given gift rule ID.
+func findGiftRule(rules []Rule, ruleID string) (int, GiftRule, bool) {
+	for i, rule := range rules {
+		if gr, ok := asGiftRule(rule); ok && gr.ID() == ruleID {
+			return i, gr, true
+		}
+	}
+
+	return 0, nil, false
+}
+
+// Selects which gift the customer wants from a gift rule which currently
+// applies. The selection is dropped if the rule stops applying.
+func (c *defaultCart) SelectGift(ruleID, prodCode string) error {
+	before := c.appliedRules
+	c.expireLocks()
+
+	index, gr, ok := findGiftRule(c.rules, ruleID)
+	if !ok {
+		c.emit(before)
+		return ErrUnknownGiftRule
+	}
+
+	if !contains(gr.Gifts(), prodCode) {
+		c.emit(before)
+		return ErrIneligibleGift
+	}
+
+	applied := false
+	for _, ar := range c.appliedRules {
+		applied = applied || ar.Index == index
+	}
+
+	if !applied {
+		c.emit(before)
+		return ErrGiftNotOffered
+	}
+
+	if c.giftSelections[ruleID] != prodCode {
+		c.checkpoint()
+		c.giftSelections[ruleID] = prodCode
+		c.engine.invalidate()
+		c.evaluateRules()
+	}
+
+	c.emit(before)
+	return nil
+}
+
+// Returns the gift selected for a gift rule, or its default if none has been.
+// Returns an empty string if there is no such rule.
+func (c *defaultCart) SelectedGift(ruleID string) string {
+	return c.basket().SelectedGift(ruleID)
+}
+
+// Drops the gift selections of gift rules which no longer apply.
+func (c *defaultCart) revalidateGifts() {
+	applied := make(map[string]bool)
+	for _, ar := range c.appliedRules {
+		if gr, ok := asGiftRule(ar.Rule); ok {
+			applied[gr.ID()] = true
+		}
+	}
+
+	for id := range c.giftSelections {
+		if !applied[id] {
+			delete(c.giftSelections, id)
+			// The rule may have been evaluated with the dropped selection.
+			c.engine.invalidate()
+		}
+	}
+}
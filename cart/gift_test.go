@@ -0,0 +1,106 @@
+package cart
+
+import (
+	"testing"
+)
+
+func checkCartContainsNBundledProductsWithCode(t *testing.T, cart Cart, prodCode string, expectedCount uint16) {
+	if v, ok := cart.BundledItems()[prodCode]; !ok {
+		if expectedCount > 0 {
+			t.Errorf("Cart didn't contain the expected bundled product code: %s", prodCode)
+		}
+	} else if v.count != expectedCount {
+		t.Errorf("Cart contained %d bundled products with code %s. Expected %d", v.count, prodCode, expectedCount)
+	}
+}
+
+func createGiftTestCart() (Cart, Catalogue) {
+	catalogue := CreateDefaultCatalogue()
+	rules := []Rule{CreateGiftRule("large-gift", "ult_large", 1, []string{"1gb", "ult_small"}, 1, "1gb")}
+
+	return CreateCart(rules, catalogue), catalogue
+}
+
+func Test_GiftRule_WHEN_NoGiftSelected_EXPECT_DefaultGiftBundled(t *testing.T) {
+	c, catalogue := createGiftTestCart()
+
+	c.Add(catalogue["ult_large"])
+
+	checkCartContainsNBundledProductsWithCode(t, c, "1gb", 1)
+	if gift := c.SelectedGift("large-gift"); gift != "1gb" {
+		t.Errorf("SelectedGift=%s, Expected=1gb", gift)
+	}
+}
+
+func Test_GiftRule_WHEN_GiftSelected_EXPECT_SelectedGiftBundled(t *testing.T) {
+	c, catalogue := createGiftTestCart()
+
+	c.Add(catalogue["ult_large"])
+	c.Add(catalogue["ult_large"])
+	if err := c.SelectGift("large-gift", "ult_small"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	checkCartContainsNBundledProductsWithCode(t, c, "ult_small", 2)
+	if _, ok := c.BundledItems()["1gb"]; ok {
+		t.Errorf("BundledItems=%v", c.BundledItems())
+	}
+
+	// The selection persists as trigger products are added.
+	c.Add(catalogue["ult_large"])
+	checkCartContainsNBundledProductsWithCode(t, c, "ult_small", 3)
+}
+
+func Test_GiftRule_WHEN_InvalidSelection_EXPECT_Error(t *testing.T) {
+	c, catalogue := createGiftTestCart()
+
+	if err := c.SelectGift("large-gift", "ult_small"); err != ErrGiftNotOffered {
+		t.Errorf("Error=%v, Expected=%v", err, ErrGiftNotOffered)
+	}
+
+	c.Add(catalogue["ult_large"])
+
+	if err := c.SelectGift("unknown", "ult_small"); err != ErrUnknownGiftRule {
+		t.Errorf("Error=%v, Expected=%v", err, ErrUnknownGiftRule)
+	}
+
+	if err := c.SelectGift("large-gift", "ult_medium"); err != ErrIneligibleGift {
+		t.Errorf("Error=%v, Expected=%v", err, ErrIneligibleGift)
+	}
+
+	checkCartContainsNBundledProductsWithCode(t, c, "1gb", 1)
+}
+
+func Test_GiftRule_WHEN_TriggerRemoved_EXPECT_SelectionDropped(t *testing.T) {
+	c, catalogue := createGiftTestCart()
+
+	c.Add(catalogue["ult_large"])
+	c.SelectGift("large-gift", "ult_small")
+	c.Remove(catalogue["ult_large"])
+
+	if len(c.BundledItems()) != 0 {
+		t.Errorf("BundledItems=%v", c.BundledItems())
+	}
+
+	c.Add(catalogue["ult_large"])
+	checkCartContainsNBundledProductsWithCode(t, c, "1gb", 1)
+
+	// Undo restores the selection along with the trigger.
+	c.Undo()
+	c.Undo()
+	checkCartContainsNBundledProductsWithCode(t, c, "ult_small", 1)
+}
+
+func Test_GiftRule_WHEN_WrappedAndReplayed_EXPECT_SelectionRestored(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rules := []Rule{CreateCustomerRule(NewCustomers, CreateGiftRule("large-gift", "ult_large", 1, []string{"1gb", "ult_small"}, 1, ""))}
+	c := CreateEventSourcedCart(rules, catalogue)
+
+	c.Add(catalogue["ult_large"])
+	if err := c.SelectGift("large-gift", "ult_small"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	rebuilt := c.Rebuild(len(c.Log()), rules, catalogue)
+	checkCartContainsNBundledProductsWithCode(t, rebuilt, "ult_small", 1)
+}
@@ -0,0 +1,266 @@
+package cart
+
+// What the outcome of a rule, condition or action depends on, so a cart need
+// only re-evaluate the rules affected by a change.
+type Dependencies struct {
+	ProductCodes []string
+	PromoCodes   []string
+	AnyProduct   bool // Depends on every product in the cart. Ie. The cart total.
+	Everything   bool // Must be re-evaluated after every change.
+}
+
+// Implemented by rules, conditions and actions which declare what they depend
+// on. Those which don't are re-evaluated after every change.
+type Dependent interface {
+	Dependencies() Dependencies
+}
+
+func dependenciesOf(x interface{}) Dependencies {
+	if d, ok := x.(Dependent); ok {
+		return d.Dependencies()
+	}
+
+	return Dependencies{Everything: true}
+}
+
+// Combines the dependencies of several rules, conditions or actions.
+func mergeDependencies(xs ...interface{}) Dependencies {
+	var deps Dependencies
+	for _, x := range xs {
+		d := dependenciesOf(x)
+		deps.ProductCodes = append(deps.ProductCodes, d.ProductCodes...)
+		deps.PromoCodes = append(deps.PromoCodes, d.PromoCodes...)
+		deps.AnyProduct = deps.AnyProduct || d.AnyProduct
+		deps.Everything = deps.Everything || d.Everything
+	}
+
+	return deps
+}
+
+// Dependencies on the given products, or on any product if none are given.
+func productDependencies(prodCodes ...string) Dependencies {
+	if len(prodCodes) == 0 {
+		return Dependencies{AnyProduct: true}
+	}
+
+	return Dependencies{ProductCodes: append([]string(nil), prodCodes...)}
+}
+
+// Disables incremental evaluation, so every rule is re-evaluated after every
+// change to the cart.
+func WithFullEvaluation() CartOption {
+	return func(c *defaultCart) {
+		c.fullEvaluation = true
+	}
+}
+
+// What a cart needs to know about each rule to evaluate it, worked out once
+// when the cart is created.
+type ruleTraits struct {
+	postOffer     bool
+	countsBundles bool
+	mode          BundleMode
+	bundleID      string // ID of the rule setting the bundle mode.
+}
+
+func ruleTraitsOf(rules []Rule) []ruleTraits {
+	traits := make([]ruleTraits, len(rules))
+	for i, rule := range rules {
+		mode, bundleID := bundleModeOf(rule)
+		traits[i] = ruleTraits{isPostOffer(rule), countsBundledItems(rule), mode, bundleID}
+	}
+
+	return traits
+}
+
+// Finds the rules affected by a change to a product or promo code, by index in
+// the rule set.
+type ruleIndex struct {
+	size       int
+	byProduct  map[string][]int
+	byPromo    map[string][]int
+	anyProduct []int
+	always     []int
+}
+
+// Promo codes are indexed once normalized, as the cart records changes to
+// them.
+func createRuleIndex(rules []Rule, dependencies func(Rule) Dependencies, normalize PromoNormalizer) *ruleIndex {
+	idx := &ruleIndex{
+		size:      len(rules),
+		byProduct: make(map[string][]int),
+		byPromo:   make(map[string][]int),
+	}
+
+	for i, rule := range rules {
+		deps := dependencies(rule)
+		switch {
+		case deps.Everything:
+			idx.always = append(idx.always, i)
+			continue
+		case deps.AnyProduct:
+			idx.anyProduct = append(idx.anyProduct, i)
+		}
+
+		for _, code := range deps.ProductCodes {
+			idx.byProduct[code] = append(idx.byProduct[code], i)
+		}
+
+		for _, code := range deps.PromoCodes {
+			code = normalize.apply(code)
+			idx.byPromo[code] = append(idx.byPromo[code], i)
+		}
+	}
+
+	return idx
+}
+
+// Returns which rules are affected by the given changes, by index in the rule
+// set.
+func (idx *ruleIndex) affected(products, promoCodes map[string]bool) []bool {
+	affected := make([]bool, idx.size)
+	for _, i := range idx.always {
+		affected[i] = true
+	}
+
+	if len(products) > 0 {
+		for _, i := range idx.anyProduct {
+			affected[i] = true
+		}
+	}
+
+	for code := range products {
+		for _, i := range idx.byProduct[code] {
+			affected[i] = true
+		}
+	}
+
+	for code := range promoCodes {
+		for _, i := range idx.byPromo[code] {
+			affected[i] = true
+		}
+	}
+
+	return affected
+}
+
+// The action of a conditional rule is only applied whilst its condition is
+// satisfied, so a change to what the action depends on is ignored whilst it
+// isn't. Ie. A promo code rule taking 10% off the cart need not be evaluated
+// as products are added, until the promo code is.
+func conditionDependencies(rule Rule) Dependencies {
+	if cr, ok := rule.(*conditionalRule); ok {
+		return dependenciesOf(cr.condition)
+	}
+
+	return dependenciesOf(rule)
+}
+
+func actionDependencies(rule Rule) Dependencies {
+	if cr, ok := rule.(*conditionalRule); ok {
+		return dependenciesOf(cr.action)
+	}
+
+	return Dependencies{}
+}
+
+// Which rules must be re-evaluated, by index in the rule set.
+type staleRules struct {
+	conditions []bool // Rules affected by a change.
+	actions    []bool // Conditional rules whose action alone is affected.
+}
+
+// Returns whether a rule must be re-evaluated given its last outcome. A nil
+// staleRules re-evaluates every rule.
+func (s *staleRules) includes(i int, r ruleResult) bool {
+	return s == nil || !r.valid || s.conditions[i] || (s.actions[i] && r.satisfied)
+}
+
+// The outcome of a rule when it was last evaluated.
+type ruleResult struct {
+	valid          bool
+	satisfied      bool // Whether the condition of a conditional rule was satisfied. Always true for other rules.
+	discount       PriceType
+	bundledProduct BundledProduct
+}
+
+func (c *defaultCart) productChanged(prodCode string) {
+	c.changedProducts[prodCode] = true
+}
+
+func (c *defaultCart) promoChanged(code string) {
+	c.changedPromoCodes[code] = true
+}
+
+// Forces every rule to be re-evaluated.
+func (e *pricingEngine) invalidate() {
+	for i := range e.results {
+		e.results[i].valid = false
+	}
+}
+
+// Returns the rules to re-evaluate given the changes since rules were last
+// evaluated, or nil if every rule must be.
+func (e *pricingEngine) staleRules(products, promoCodes map[string]bool) *staleRules {
+	if e.conditionIndex == nil {
+		return nil
+	}
+
+	return &staleRules{
+		e.conditionIndex.affected(products, promoCodes),
+		e.actionIndex.affected(products, promoCodes),
+	}
+}
+
+// Returns whether a rule can be skipped as it contributed nothing when last
+// evaluated and need not be re-evaluated.
+func (e *pricingEngine) unchangedAndUnapplied(i int, stale *staleRules) bool {
+	r := e.results[i]
+	return !stale.includes(i, r) && r.discount == 0 && (r.bundledProduct.count == 0 || r.bundledProduct.code == "")
+}
+
+// Evaluates a rule, or returns its previous outcome if nothing it depends on
+// has changed since.
+func (e *pricingEngine) evaluateRule(i int, rule Rule, view Basket, stale *staleRules) (PriceType, BundledProduct) {
+	r := &e.results[i]
+	if !stale.includes(i, *r) {
+		return r.discount, r.bundledProduct
+	}
+
+	if cr, ok := rule.(*conditionalRule); ok {
+		r.satisfied, r.discount, r.bundledProduct = cr.evaluate(view)
+	} else {
+		r.discount, r.bundledProduct = rule.Evaluate(view)
+		r.satisfied = true
+	}
+	r.valid = true
+
+	return r.discount, r.bundledProduct
+}
+
+func mergeConditions(conditions []Condition) Dependencies {
+	xs := make([]interface{}, len(conditions))
+	for i, c := range conditions {
+		xs[i] = c
+	}
+
+	return mergeDependencies(xs...)
+}
+
+func setDependencies(set map[string]uint16) Dependencies {
+	var deps Dependencies
+	for code := range set {
+		deps.ProductCodes = append(deps.ProductCodes, code)
+	}
+
+	return deps
+}
+
+// Post-offer spend depends on the discounts of other rules.
+func spendDependencies(basis SpendBasis, prodCodes []string) Dependencies {
+	if basis == PostOfferSpend {
+		return Dependencies{Everything: true}
+	}
+
+	return productDependencies(prodCodes...)
+}
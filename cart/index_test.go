@@ -0,0 +1,200 @@
+package cart
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func createIndexTestRules() []Rule {
+	script, _ := CreateScriptRule(`discount = count("ult_medium") >= 2 ? 100 : 0`, ScriptLimits{})
+
+	return append(CreateDefaultRules(),
+		CreateNthItemDiscountRule(2, 50, "ult_small", "ult_medium"),
+		CreateCheapestItemFreeRule(5),
+		CreateSpendThresholdRule(10000, UndiscountedSpend, FixedDiscount(1000), CreateDefaultCategories()["sim"]...),
+		CreateSpendThresholdRule(15000, PostOfferSpend, FreeProduct("1gb", 1)),
+		CreateFixedPriceBundleRule(map[string]uint16{"ult_large": 1, "1gb": 1}, 5000),
+		CreateGiftRule("large-gift", "ult_large", 2, []string{"1gb", "ult_small"}, 1, ""),
+		CreateBundleModeRule("small-data", CreateBundleRule("ult_small", 2, "1gb", 1), DeclinableBundle),
+		CreateBundleAwareRule(CreateXForYRule("1gb", 2, 1)),
+		CreateCustomerRule(NewCustomers, CreatePromoRule("WELCOME", 5)),
+		CreateConditionalRule(And(HasPromoCode("SIMS"), Not(MinQuantity("1gb", 1))), PercentOffItems(3)),
+		script,
+	)
+}
+
+func checkCartsEquivalent(t *testing.T, step string, expected, actual Cart) {
+	if expected.Total() != actual.Total() {
+		t.Fatalf("%s: CartTotal=%d, Expected=%d", step, actual.Total(), expected.Total())
+	}
+
+	if !reflect.DeepEqual(expected.BundledItems(), actual.BundledItems()) ||
+		!reflect.DeepEqual(expected.SuggestedBundles(), actual.SuggestedBundles()) {
+		t.Fatalf("%s: BundledItems=%v SuggestedBundles=%v, Expected=%v %v", step,
+			actual.BundledItems(), actual.SuggestedBundles(), expected.BundledItems(), expected.SuggestedBundles())
+	}
+
+	e, a := expected.(*defaultCart).appliedRules, actual.(*defaultCart).appliedRules
+	if len(e) != len(a) {
+		t.Fatalf("%s: AppliedRules=%v, Expected=%v", step, a, e)
+	}
+
+	for i := range e {
+		if e[i].Index != a[i].Index || e[i].Discount != a[i].Discount || e[i].BundledProduct != a[i].BundledProduct {
+			t.Fatalf("%s: AppliedRules=%v, Expected=%v", step, a, e)
+		}
+	}
+}
+
+func Test_Index_WHEN_RandomInteractions_EXPECT_SameAsFullEvaluation(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	codes := []string{"ult_small", "ult_medium", "ult_large", "1gb"}
+	promoCodes := []string{"I<3AMAYSIM", "WELCOME", "SIMS"}
+	rules := createIndexTestRules()
+
+	full := CreateCart(rules, catalogue, WithFullEvaluation())
+	incremental := CreateCart(rules, catalogue)
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 2000; i++ {
+		var step string
+		var op func(c Cart)
+
+		switch n := r.Intn(20); {
+		case n < 8:
+			p := catalogue[codes[r.Intn(len(codes))]]
+			step, op = "Add "+p.Code, func(c Cart) { c.Add(p) }
+		case n < 12:
+			p := catalogue[codes[r.Intn(len(codes))]]
+			step, op = "Remove "+p.Code, func(c Cart) { c.Remove(p) }
+		case n == 12:
+			code := promoCodes[r.Intn(len(promoCodes))]
+			step, op = "AddPromoCode "+code, func(c Cart) { c.AddPromoCode(code) }
+		case n == 13:
+			code := promoCodes[r.Intn(len(promoCodes))]
+			step, op = "RemovePromoCode "+code, func(c Cart) { c.RemovePromoCode(code) }
+		case n == 14:
+			step, op = "Undo", func(c Cart) { c.Undo() }
+		case n == 15:
+			step, op = "Redo", func(c Cart) { c.Redo() }
+		case n == 16:
+			gift := []string{"1gb", "ult_small"}[r.Intn(2)]
+			step, op = "SelectGift "+gift, func(c Cart) { c.SelectGift("large-gift", gift) }
+		case n == 17:
+			accept := r.Intn(2) == 0
+			step, op = fmt.Sprintf("ChooseBundle %v", accept), func(c Cart) {
+				if accept {
+					c.AcceptBundle("small-data", "1gb")
+				} else {
+					c.DeclineBundle("small-data", "1gb")
+				}
+			}
+		case n == 18:
+			customer := Customer{}
+			if r.Intn(2) == 0 {
+				customer.ExistingServices = []string{"ult_small"}
+			}
+			step, op = "SetCustomer", func(c Cart) { c.SetCustomer(customer) }
+		default:
+			step, op = "Clear", func(c Cart) { c.Clear() }
+		}
+
+		op(full)
+		op(incremental)
+		checkCartsEquivalent(t, fmt.Sprintf("%d %s", i, step), full, incremental)
+	}
+}
+
+func Test_Index_WHEN_ProductAdded_EXPECT_OnlyAffectedRulesEvaluated(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	counts := make([]int, 3)
+	rules := make([]Rule, 3)
+	for i, code := range []string{"ult_small", "ult_medium", "ult_large"} {
+		i := i
+		rules[i] = CreateConditionalRule(MinQuantity(code, 1), &countingAction{DiscountEachUnit(code, 1), &counts[i]})
+	}
+
+	c := CreateCart(rules, catalogue)
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_large"])
+
+	// Every rule is evaluated the first time, then only those for the product added.
+	if counts[0] != 2 || counts[1] != 0 || counts[2] != 1 {
+		t.Errorf("Evaluations=%v, Expected=[2 0 1]", counts)
+	}
+
+	if c.Total() != 2*2490+4490-3 {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), 2*2490+4490-3)
+	}
+}
+
+// Counts how often an action is applied.
+type countingAction struct {
+	action Action
+	count  *int
+}
+
+func (a *countingAction) Dependencies() Dependencies {
+	return dependenciesOf(a.action)
+}
+
+func (a *countingAction) Apply(c Basket) (PriceType, BundledProduct) {
+	*a.count++
+	return a.action.Apply(c)
+}
+
+// A catalogue and rule set the size of a national catalogue of regional offers.
+func createBenchmarkCart(b *testing.B, opts ...CartOption) (Cart, []Product) {
+	catalogue := make(Catalogue)
+	var products []Product
+	for i := 0; i < 500; i++ {
+		p := Product{fmt.Sprintf("product-%d", i), "", PriceType(1000 + i)}
+		catalogue[p.Code] = p
+		products = append(products, p)
+	}
+
+	var rules []Rule
+	for i := 0; i < 2000; i++ {
+		code := products[i%len(products)].Code
+		switch i % 4 {
+		case 0:
+			rules = append(rules, CreateXForYRule(code, 3, 2))
+		case 1:
+			rules = append(rules, CreateBulkDiscountRule(code, 5, 100))
+		case 2:
+			rules = append(rules, CreateBundleRule(code, 2, products[(i+1)%len(products)].Code, 1))
+		default:
+			rules = append(rules, CreatePromoRule(fmt.Sprintf("REGION%d", i), 5))
+		}
+	}
+
+	opts = append(opts, WithUndoLimit(0))
+	c := CreateCart(rules, catalogue, opts...)
+	for i := 0; i < 20; i++ {
+		c.Add(products[i*7])
+	}
+	b.ResetTimer()
+
+	return c, products
+}
+
+func benchmarkAddRemove(b *testing.B, opts ...CartOption) {
+	c, products := createBenchmarkCart(b, opts...)
+
+	for i := 0; i < b.N; i++ {
+		p := products[i%len(products)]
+		c.Add(p)
+		c.Remove(p)
+	}
+}
+
+func Benchmark_AddRemove_FullEvaluation(b *testing.B) {
+	benchmarkAddRemove(b, WithFullEvaluation())
+}
+
+func Benchmark_AddRemove_IncrementalEvaluation(b *testing.B) {
+	benchmarkAddRemove(b)
+}
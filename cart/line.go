@@ -0,0 +1,90 @@
+package cart
+
+import (
+	"errors"
+)
+
+var ErrUnknownLine = errors.New("cart: no line with that ID")
+
+// A single unit of a product in the cart, with attributes specific to that
+// unit. Ie. The MSISDN or port-in details of a SIM.
+type LineItem struct {
+	ID         string
+	Product    Product
+	Attributes map[string]string
+}
+
+type cartLine struct {
+	id         string
+	code       string
+	attributes map[string]string
+}
+
+func copyAttributes(attributes map[string]string) map[string]string {
+	c := make(map[string]string, len(attributes))
+	for k, v := range attributes {
+		c[k] = v
+	}
+
+	return c
+}
+
+func copyLines(lines []*cartLine) []*cartLine {
+	c := make([]*cartLine, len(lines))
+	for i, l := range lines {
+		c[i] = &cartLine{l.id, l.code, copyAttributes(l.attributes)}
+	}
+
+	return c
+}
+
+func (c *defaultCart) findLine(id string) int {
+	for i, l := range c.lines {
+		if l.id == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Returns the lines in the order they were added. Products are priced as they
+// are in Items().
+func (c *defaultCart) Lines() []LineItem {
+	lines := make([]LineItem, len(c.lines))
+	for i, l := range c.lines {
+		lines[i] = LineItem{l.id, c.products[l.code].product, copyAttributes(l.attributes)}
+	}
+
+	return lines
+}
+
+func (c *defaultCart) RemoveLine(id string) error {
+	before := c.appliedRules
+	c.expireLocks()
+
+	i := c.findLine(id)
+	if i < 0 {
+		c.emit(before)
+		return ErrUnknownLine
+	}
+
+	c.checkpoint()
+	removed, _ := c.removeLineAt(i)
+	c.evaluateRules()
+	c.emit(before, Event{Type: ItemRemoved, Product: removed, LineID: id})
+
+	return nil
+}
+
+func (c *defaultCart) SetLineAttribute(id, key, value string) error {
+	i := c.findLine(id)
+	if i < 0 {
+		return ErrUnknownLine
+	}
+
+	c.checkpoint()
+	c.lines[i].attributes[key] = value
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package cart
+
+import (
+	"testing"
+)
+
+func Test_Line_WHEN_LinesAddedWithAttributes_EXPECT_UniqueLinesAndAggregateItems(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(CreateDefaultRules(), catalogue)
+
+	id1, _ := c.AddLine(catalogue["ult_small"], map[string]string{"msisdn": "0412000001", "port_in": "true"})
+	id2, _ := c.AddLine(catalogue["ult_small"], nil)
+	c.Add(catalogue["ult_small"])
+
+	lines := c.Lines()
+	if len(lines) != 3 || id1 == id2 || lines[0].ID != id1 || lines[1].ID != id2 {
+		t.Fatalf("Lines=%v", lines)
+	}
+
+	if lines[0].Attributes["msisdn"] != "0412000001" || len(lines[1].Attributes) != 0 {
+		t.Errorf("Lines=%v", lines)
+	}
+
+	checkCartContainsNProductsWithCode(t, c, "ult_small", 3)
+
+	// Rules see aggregate quantities.
+	if c.Total() != 2*2490 {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), 2*2490)
+	}
+}
+
+func Test_Line_WHEN_SpecificLineRemoved_EXPECT_OnlyThatLineRemoved(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(CreateDefaultRules(), catalogue)
+
+	id1, _ := c.AddLine(catalogue["ult_small"], map[string]string{"msisdn": "0412000001"})
+	id2, _ := c.AddLine(catalogue["ult_small"], map[string]string{"msisdn": "0412000002"})
+	id3, _ := c.AddLine(catalogue["ult_small"], map[string]string{"msisdn": "0412000003"})
+
+	if err := c.RemoveLine(id2); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lines := c.Lines()
+	if len(lines) != 2 || lines[0].ID != id1 || lines[1].ID != id3 {
+		t.Errorf("Lines=%v", lines)
+	}
+
+	if c.Total() != 2*2490 {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), 2*2490)
+	}
+
+	if err := c.RemoveLine(id2); err != ErrUnknownLine {
+		t.Errorf("Error=%v, Expected=%v", err, ErrUnknownLine)
+	}
+
+	// Remove by product takes the most recently added line.
+	c.Remove(catalogue["ult_small"])
+	if lines := c.Lines(); len(lines) != 1 || lines[0].ID != id1 {
+		t.Errorf("Lines=%v", lines)
+	}
+}
+
+func Test_Line_WHEN_AttributeSet_EXPECT_LineUpdatedAndUndoable(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(nil, catalogue)
+
+	id, _ := c.AddLine(catalogue["ult_medium"], nil)
+
+	if err := c.SetLineAttribute(id, "sim_type", "esim"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if c.Lines()[0].Attributes["sim_type"] != "esim" {
+		t.Errorf("Lines=%v", c.Lines())
+	}
+
+	c.Lines()[0].Attributes["sim_type"] = "physical"
+	if c.Lines()[0].Attributes["sim_type"] != "esim" {
+		t.Errorf("Line attributes were mutable via Lines()")
+	}
+
+	c.Undo()
+	if _, ok := c.Lines()[0].Attributes["sim_type"]; ok {
+		t.Errorf("Lines=%v after undo", c.Lines())
+	}
+
+	if err := c.SetLineAttribute("missing", "sim_type", "esim"); err != ErrUnknownLine {
+		t.Errorf("Error=%v, Expected=%v", err, ErrUnknownLine)
+	}
+}
+
+func Test_Line_GIVEN_EventSourcedCart_WHEN_Replayed_EXPECT_LinesRebuilt(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateEventSourcedCart(nil, catalogue)
+
+	id, _ := c.AddLine(catalogue["ult_small"], map[string]string{"msisdn": "0412000001"})
+	c.Add(catalogue["ult_small"])
+	c.SetLineAttribute(id, "port_in", "true")
+	c.RemoveLine(id)
+	c.Undo()
+
+	r := c.Rebuild(len(c.Log()), nil, catalogue)
+	lines := r.Lines()
+	if len(lines) != 2 || lines[0].ID != id || lines[0].Attributes["port_in"] != "true" {
+		t.Errorf("Lines=%v", lines)
+	}
+}
@@ -0,0 +1,119 @@
+package cart
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var ErrEmptyCart = errors.New("cart: cannot checkout a cart with no items")
+
+// Records a rule which contributed to a cart total, and what it contributed
+// at the time it was evaluated.
+type AppliedRule struct {
+	Index          int    // Position of the rule in the rule set.
+	Rule           Rule   // The rule itself, not a copy.
+	Description    string // The rule's description when it was evaluated.
+	Discount       PriceType
+	BundledProduct BundledProduct
+}
+
+func createAppliedRule(index int, rule Rule, discount PriceType, bp BundledProduct) AppliedRule {
+	return AppliedRule{index, rule, fmt.Sprint(rule), discount, bp}
+}
+
+// An Order is an immutable snapshot of a cart taken at checkout.
+// Products are held by value, and each applied rule's description and what it
+// contributed are recorded, so later changes to the Catalogue or the rule set
+// do not alter an order's totals. AppliedRule.Rule still refers to the rule
+// itself, which a caller's own Rule implementation may change.
+type Order struct {
+	placedAt          time.Time
+	customer          Customer
+	items             ProductCollectionType
+	lines             []LineItem
+	bundledItems      ProductCollectionType
+	promoCodes        []string
+	appliedRules      []AppliedRule
+	undiscountedTotal PriceType
+	discount          PriceType
+}
+
+func (o Order) PlacedAt() time.Time {
+	return o.placedAt
+}
+
+func (o Order) Customer() Customer {
+	return o.customer.copy()
+}
+
+func (o Order) Items() ProductCollectionType {
+	return o.items.copy()
+}
+
+func (o Order) Lines() []LineItem {
+	lines := make([]LineItem, len(o.lines))
+	for i, l := range o.lines {
+		lines[i] = LineItem{l.ID, l.Product, copyAttributes(l.Attributes)}
+	}
+
+	return lines
+}
+
+func (o Order) BundledItems() ProductCollectionType {
+	return o.bundledItems.copy()
+}
+
+func (o Order) PromoCodes() []string {
+	return append([]string{}, o.promoCodes...)
+}
+
+func (o Order) AppliedRules() []AppliedRule {
+	return append([]AppliedRule{}, o.appliedRules...)
+}
+
+func (o Order) UndiscountedTotal() PriceType {
+	return o.undiscountedTotal
+}
+
+func (o Order) Discount() PriceType {
+	return o.discount
+}
+
+func (o Order) Total() PriceType {
+	return o.undiscountedTotal - o.discount
+}
+
+func (c *defaultCart) Checkout() (Order, error) {
+	before := c.appliedRules
+	c.expireLocks()
+	c.emit(before)
+
+	if len(c.products) == 0 {
+		return Order{}, ErrEmptyCart
+	}
+
+	if violations := c.Violations(); len(violations) > 0 {
+		return Order{}, violations[0]
+	}
+
+	if c.pricingErr != nil {
+		return Order{}, c.pricingErr
+	}
+
+	promoCodes := c.PromoCodes()
+	sort.Strings(promoCodes)
+
+	return Order{
+		placedAt:          c.now(),
+		customer:          c.customer.copy(),
+		items:             c.products.copy(),
+		lines:             c.Lines(),
+		bundledItems:      c.bundleProducts.copy(),
+		promoCodes:        promoCodes,
+		appliedRules:      append([]AppliedRule{}, c.appliedRules...),
+		undiscountedTotal: c.undiscountedTotal,
+		discount:          c.discount,
+	}, nil
+}
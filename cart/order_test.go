@@ -0,0 +1,84 @@
+package cart
+
+import (
+	"testing"
+)
+
+func Test_Order_GIVEN_EmptyCart_WHEN_CheckedOut_EXPECT_Error(t *testing.T) {
+	c := CreateCart(CreateDefaultRules(), CreateDefaultCatalogue())
+
+	if _, err := c.Checkout(); err != ErrEmptyCart {
+		t.Errorf("ActualError=%v ExpectedError=%v", err, ErrEmptyCart)
+	}
+}
+
+func Test_Order_WHEN_CheckedOut_EXPECT_OrderMatchesCart(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(CreateDefaultRules(), catalogue)
+
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_medium"])
+	c.Add(catalogue["ult_medium"])
+
+	o, err := c.Checkout()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if o.Total() != c.Total() {
+		t.Errorf("OrderTotal=%d CartTotal=%d", o.Total(), c.Total())
+	}
+
+	ua, ue := checkItemsAgainstExpectations(t, o.Items(), []ProductCodeCount{{"ult_small", 1}, {"ult_medium", 2}})
+	if len(ua) > 0 || len(ue) > 0 {
+		t.Errorf("Order items mismatch. UnexpectedItems=%s, UnmatchedExpectations=%s", ua, ue)
+	}
+
+	ua, ue = checkItemsAgainstExpectations(t, o.BundledItems(), []ProductCodeCount{{"1gb", 2}})
+	if len(ua) > 0 || len(ue) > 0 {
+		t.Errorf("Order bundled items mismatch. UnexpectedItems=%s, UnmatchedExpectations=%s", ua, ue)
+	}
+
+	if len(o.AppliedRules()) != 1 {
+		t.Fatalf("AppliedRules=%v Expected one bundle rule", o.AppliedRules())
+	}
+
+	if o.AppliedRules()[0].BundledProduct != (BundledProduct{"1gb", 2}) {
+		t.Errorf("AppliedRule=%v", o.AppliedRules()[0])
+	}
+}
+
+func Test_Order_WHEN_CartAndCatalogueChangeAfterCheckout_EXPECT_OrderUnchanged(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(CreateDefaultRules(), catalogue)
+
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+	c.AddPromoCode("I<3AMAYSIM")
+
+	o, _ := c.Checkout()
+	expectedTotal := o.Total()
+	expectedRules := len(o.AppliedRules())
+
+	catalogue["ult_small"] = Product{"ult_small", "Unlimited 1GB", 9990}
+	c.Clear()
+	c.Add(catalogue["ult_small"])
+
+	if o.Total() != expectedTotal {
+		t.Errorf("OrderTotal=%d Expected=%d", o.Total(), expectedTotal)
+	}
+
+	if o.Items()["ult_small"].count != 3 || o.Items()["ult_small"].product.Price != 2490 {
+		t.Errorf("Order items changed: %v", o.Items()["ult_small"])
+	}
+
+	if len(o.AppliedRules()) != expectedRules || len(o.PromoCodes()) != 1 {
+		t.Errorf("AppliedRules=%v PromoCodes=%v", o.AppliedRules(), o.PromoCodes())
+	}
+
+	o.Items()["ult_small"].count = 1
+	if o.Items()["ult_small"].count != 3 {
+		t.Errorf("Order items were mutable via Items()")
+	}
+}
@@ -0,0 +1,144 @@
+package cart
+
+import (
+	"sort"
+	"time"
+)
+
+type PriceChange struct {
+	Code     string
+	OldPrice PriceType
+	NewPrice PriceType
+}
+
+// Describes what changed when a cart was re-priced after a price lock expired.
+type RepriceNotice struct {
+	At            time.Time
+	PriceChanges  []PriceChange
+	ExpiredOffers []AppliedRule // Offers which applied before re-pricing, but no longer do.
+	OldTotal      PriceType
+	NewTotal      PriceType
+}
+
+// Guarantees the price of a product, and the offers available, for the given
+// window after the product (or a promo code) is added to the cart. Once the
+// window lapses the cart is re-priced against the Catalogue and the currently
+// active rules on its next interaction.
+func WithPriceLock(window time.Duration) CartOption {
+	return func(c *defaultCart) {
+		c.lockWindow = window
+	}
+}
+
+// Registers a function to be called whenever an expired price lock changes
+// the prices or offers in the cart.
+func WithRepriceHandler(handler func(RepriceNotice)) CartOption {
+	return func(c *defaultCart) {
+		c.onReprice = handler
+	}
+}
+
+// Locks the price of the given product code (if any) and every offer active
+// now, for the lock window.
+func (c *defaultCart) lock(prodCode string) {
+	if c.lockWindow <= 0 {
+		return
+	}
+
+	now := c.now()
+	until := now.Add(c.lockWindow)
+
+	if prodCode != "" {
+		c.priceLocks[prodCode] = until
+	}
+
+	for i, rule := range c.rules {
+		if ruleActiveAt(rule, now) && c.ruleLocks[i].Before(until) {
+			c.ruleLocks[i] = until
+		}
+	}
+}
+
+// A rule may be evaluated if it is active now, or was active when a lock which
+// is still held was taken.
+func (c *defaultCart) ruleAvailable(index int, rule Rule, now time.Time) bool {
+	if ruleActiveAt(rule, now) {
+		return true
+	}
+
+	until, ok := c.ruleLocks[index]
+	return ok && now.Before(until)
+}
+
+// Releases any lapsed locks, re-pricing the cart and notifying the reprice
+// handler if the release changed anything.
+func (c *defaultCart) expireLocks() {
+	if c.lockWindow <= 0 {
+		return
+	}
+
+	now := c.now()
+	oldTotal := c.Total()
+	expired := false
+	var priceChanges []PriceChange
+
+	for code, until := range c.priceLocks {
+		if now.Before(until) {
+			continue
+		}
+
+		expired = true
+		delete(c.priceLocks, code)
+
+		v, ok := c.products[code]
+		if !ok {
+			continue
+		}
+
+		// Products no longer in the catalogue keep their last price.
+		if current, ok := c.catalogue[code]; ok && current.Price != v.product.Price {
+			priceChanges = append(priceChanges, PriceChange{code, v.product.Price, current.Price})
+			c.undiscountedTotal += PriceType(v.count) * (current.Price - v.product.Price)
+			v.product = current
+			c.productChanged(code)
+		}
+	}
+
+	for i, until := range c.ruleLocks {
+		if !now.Before(until) {
+			expired = true
+			delete(c.ruleLocks, i)
+		}
+	}
+
+	if !expired {
+		return
+	}
+
+	oldApplied := c.appliedRules
+	c.evaluateRules()
+
+	stillApplied := make(map[int]bool)
+	for _, ar := range c.appliedRules {
+		stillApplied[ar.Index] = true
+	}
+
+	var expiredOffers []AppliedRule
+	for _, old := range oldApplied {
+		if !stillApplied[old.Index] && !ruleActiveAt(old.Rule, now) {
+			expiredOffers = append(expiredOffers, old)
+		}
+	}
+
+	sort.Slice(priceChanges, func(i, j int) bool {
+		return priceChanges[i].Code < priceChanges[j].Code
+	})
+
+	if len(priceChanges) == 0 && len(expiredOffers) == 0 {
+		return
+	}
+
+	if c.onReprice != nil {
+		c.onReprice(RepriceNotice{now, priceChanges, expiredOffers, oldTotal, c.Total()})
+	}
+}
@@ -0,0 +1,124 @@
+package cart
+
+import (
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	t time.Time
+}
+
+func (c *testClock) now() time.Time {
+	return c.t
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func createTestClock() *testClock {
+	return &testClock{time.Date(2017, 6, 1, 14, 0, 0, 0, time.UTC)}
+}
+
+func Test_PriceLock_WHEN_CataloguePriceChangesWithinLock_EXPECT_LockedPrice(t *testing.T) {
+	clock := createTestClock()
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(nil, catalogue, WithClock(clock.now), WithPriceLock(15*time.Minute))
+
+	c.Add(catalogue["ult_small"])
+	catalogue["ult_small"] = Product{"ult_small", "Unlimited 1GB", 2990}
+
+	clock.advance(10 * time.Minute)
+	c.Add(catalogue["ult_small"])
+
+	if c.Total() != 2*2490 {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), 2*2490)
+	}
+}
+
+func Test_PriceLock_WHEN_CataloguePriceChangesAndLockExpires_EXPECT_RepricedWithNotice(t *testing.T) {
+	clock := createTestClock()
+	catalogue := CreateDefaultCatalogue()
+	var notices []RepriceNotice
+	c := CreateCart(nil, catalogue,
+		WithClock(clock.now),
+		WithPriceLock(15*time.Minute),
+		WithRepriceHandler(func(n RepriceNotice) { notices = append(notices, n) }))
+
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+	catalogue["ult_small"] = Product{"ult_small", "Unlimited 1GB", 2990}
+
+	clock.advance(20 * time.Minute)
+	c.Add(catalogue["1gb"])
+
+	expectedTotal := PriceType(2*2990 + 990)
+	if c.Total() != expectedTotal {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), expectedTotal)
+	}
+
+	if len(notices) != 1 {
+		t.Fatalf("Notices=%v, Expected one", notices)
+	}
+
+	n := notices[0]
+	if len(n.PriceChanges) != 1 || n.PriceChanges[0] != (PriceChange{"ult_small", 2490, 2990}) {
+		t.Errorf("PriceChanges=%v", n.PriceChanges)
+	}
+
+	if n.OldTotal != 2*2490 || n.NewTotal != 2*2990 {
+		t.Errorf("OldTotal=%d NewTotal=%d", n.OldTotal, n.NewTotal)
+	}
+}
+
+func Test_PriceLock_WHEN_OfferEndsWithinLock_EXPECT_OfferHonouredUntilLockExpires(t *testing.T) {
+	clock := createTestClock()
+	catalogue := CreateDefaultCatalogue()
+	offer := CreateScheduledRule(CreateXForYRule("ult_small", 3, 2), time.Time{}, clock.t.Add(5*time.Minute))
+	var notices []RepriceNotice
+	c := CreateCart([]Rule{offer}, catalogue,
+		WithClock(clock.now),
+		WithPriceLock(15*time.Minute),
+		WithRepriceHandler(func(n RepriceNotice) { notices = append(notices, n) }))
+
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+	clock.advance(10 * time.Minute)
+	c.Add(catalogue["ult_small"])
+
+	if c.Total() != 2*2490 {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), 2*2490)
+	}
+
+	clock.advance(30 * time.Minute)
+	c.RemovePromoCode("none")
+
+	if c.Total() != 3*2490 {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), 3*2490)
+	}
+
+	if len(notices) != 1 || len(notices[0].ExpiredOffers) != 1 {
+		t.Fatalf("Notices=%v, Expected one expired offer", notices)
+	}
+
+	if notices[0].ExpiredOffers[0].Discount != 2490 {
+		t.Errorf("ExpiredOffer=%v", notices[0].ExpiredOffers[0])
+	}
+}
+
+func Test_PriceLock_GIVEN_NoLock_WHEN_OfferHasEnded_EXPECT_OfferNotApplied(t *testing.T) {
+	clock := createTestClock()
+	catalogue := CreateDefaultCatalogue()
+	offer := CreateScheduledRule(CreateXForYRule("ult_small", 3, 2), time.Time{}, clock.t.Add(5*time.Minute))
+	c := CreateCart([]Rule{offer}, catalogue, WithClock(clock.now))
+
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+	clock.advance(10 * time.Minute)
+	c.Add(catalogue["ult_small"])
+
+	if c.Total() != 3*2490 {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), 3*2490)
+	}
+}
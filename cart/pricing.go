@@ -0,0 +1,290 @@
+package cart
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// A read only view of what is being priced. This is all a rule can see of a
+// cart.
+type Basket interface {
+	Items() ProductCollectionType
+	PromoCodes() []string
+	// Whether the promo code, once normalized, has been entered.
+	HasPromoCode(code string) bool
+	// The total of the items less the discounts of the rules evaluated so far.
+	Total() PriceType
+	Customer() Customer
+	Catalogue() Catalogue
+	// Returns the gift selected for a gift rule, or its default if none has
+	// been. Returns an empty string if there is no such rule.
+	SelectedGift(ruleID string) string
+}
+
+// Everything other than the items and promo codes which affects a price.
+type PricingContext struct {
+	At             time.Time // Scheduled rules apply if active at this time. Zero uses the current time.
+	Customer       Customer
+	GiftSelections map[string]string    // Gift rule ID => selected product code.
+	BundleChoices  map[BundleOffer]bool // Accepted (true) or declined (false) optional bundles.
+	NormalizePromo PromoNormalizer      // Applied to the promo codes and to the codes rules match. Nil matches codes exactly.
+}
+
+type PricingResult struct {
+	Items             ProductCollectionType
+	BundledItems      ProductCollectionType
+	SuggestedBundles  ProductCollectionType // Optional bundles declined or not yet accepted.
+	AppliedRules      []AppliedRule         // In rule set order.
+	UndiscountedTotal PriceType
+	Discount          PriceType
+	Total             PriceType
+	Err               error // ErrBundlesUnsettled if bundles fed each other without settling.
+
+	optionalBundles map[BundleOffer]bool // Offered by declinable or opt-in bundles.
+}
+
+// Prices a basket of items and promo codes against the rules, without a cart.
+// The inputs are not modified, and pricing the same inputs at the same time
+// always gives the same result.
+func Price(catalogue Catalogue, rules []Rule, items ProductCollectionType, promoCodes []string, ctx PricingContext) PricingResult {
+	return createPricingEngine(catalogue, rules, false, nil).priceBasket(items, promoCodes, ctx)
+}
+
+// Prices a basket from scratch. A non-incremental engine may price any number
+// of baskets in turn, but not concurrently.
+func (e *pricingEngine) priceBasket(items ProductCollectionType, promoCodes []string, ctx PricingContext) PricingResult {
+	at := ctx.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	return e.priceWith(items, promoCodes, ctx, func(i int, rule Rule) bool {
+		return ruleActiveAt(rule, at)
+	})
+}
+
+// Prices a basket from scratch, skipping rules for which available returns
+// false. ctx.At is ignored.
+func (e *pricingEngine) priceWith(items ProductCollectionType, promoCodes []string, ctx PricingContext, available func(int, Rule) bool) PricingResult {
+	b := &basket{
+		engine:         e,
+		items:          items.copy(),
+		promoCodes:     normalizedCodes(promoCodes, ctx.NormalizePromo),
+		normalize:      ctx.NormalizePromo,
+		customer:       ctx.Customer.copy(),
+		giftSelections: ctx.GiftSelections,
+	}
+
+	for _, v := range b.items {
+		b.undiscountedTotal += PriceType(v.count) * v.product.Price
+	}
+
+	return e.price(b, ctx.BundleChoices, nil, available)
+}
+
+// Returns the codes sorted, without duplicates.
+func sortedCodes(codes []string) []string {
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+
+	sorted := make([]string, 0, len(set))
+	for code := range set {
+		sorted = append(sorted, code)
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// The contents of a basket whilst it is priced.
+type basket struct {
+	engine            *pricingEngine
+	items             ProductCollectionType
+	promoCodes        []string // Normalized and sorted.
+	normalize         PromoNormalizer
+	customer          Customer
+	giftSelections    map[string]string
+	undiscountedTotal PriceType
+	discount          PriceType // Of the rules evaluated so far.
+}
+
+func (b *basket) Items() ProductCollectionType {
+	return b.items
+}
+
+func (b *basket) PromoCodes() []string {
+	return append([]string(nil), b.promoCodes...)
+}
+
+func (b *basket) HasPromoCode(code string) bool {
+	code = b.normalize.apply(code)
+	i := sort.SearchStrings(b.promoCodes, code)
+	return i < len(b.promoCodes) && b.promoCodes[i] == code
+}
+
+func (b *basket) Total() PriceType {
+	return b.undiscountedTotal - b.discount
+}
+
+func (b *basket) Customer() Customer {
+	return b.customer.copy()
+}
+
+func (b *basket) Catalogue() Catalogue {
+	return b.engine.catalogue
+}
+
+func (b *basket) SelectedGift(ruleID string) string {
+	if gift, ok := b.giftSelections[ruleID]; ok {
+		return gift
+	}
+
+	if _, gr, ok := findGiftRule(b.engine.rules, ruleID); ok {
+		return gr.DefaultGift()
+	}
+
+	return ""
+}
+
+// Evaluates a rule set. An incremental engine remembers the outcome of each
+// rule so that only rules affected by a change need be re-evaluated.
+type pricingEngine struct {
+	catalogue      Catalogue
+	rules          []Rule
+	traits         []ruleTraits
+	results        []ruleResult // Rule index => outcome when last evaluated.
+	conditionIndex *ruleIndex   // Nil unless incremental.
+	actionIndex    *ruleIndex
+}
+
+func createPricingEngine(catalogue Catalogue, rules []Rule, incremental bool, normalize PromoNormalizer) *pricingEngine {
+	e := &pricingEngine{
+		catalogue: catalogue,
+		rules:     rules,
+		traits:    ruleTraitsOf(rules),
+		results:   make([]ruleResult, len(rules)),
+	}
+
+	if incremental {
+		e.conditionIndex = createRuleIndex(rules, conditionDependencies, normalize)
+		e.actionIndex = createRuleIndex(rules, actionDependencies, normalize)
+	}
+
+	return e
+}
+
+// Prices the basket, re-evaluating the stale rules, or every rule if stale is
+// nil. Rules for which available returns false are skipped.
+func (e *pricingEngine) price(b *basket, bundleChoices map[BundleOffer]bool, stale *staleRules, available func(int, Rule) bool) PricingResult {
+	// Rules which count bundled items see those bundled by the previous pass,
+	// so evaluate until the bundles settle.
+	var result PricingResult
+	var previous map[int]BundledProduct
+	for pass := 1; ; pass++ {
+		result = b.createResult()
+		bundled := e.pricePass(b, &result, bundleChoices, previous, stale, available)
+		if !e.hasBundleAwareRules() || sameBundles(bundled, previous) {
+			break
+		}
+		previous = bundled
+
+		// Only rules which count bundled items see anything new.
+		stale = e.staleRules(nil, nil)
+
+		if pass == maxBundlePasses {
+			// Bundles which never settle grant nothing beyond what the
+			// rules give without counting bundled items.
+			result = b.createResult()
+			e.pricePass(b, &result, bundleChoices, nil, stale, available)
+			result.Err = ErrBundlesUnsettled
+			break
+		}
+	}
+
+	result.Discount = b.discount
+	result.Total = b.Total()
+
+	return result
+}
+
+func (b *basket) createResult() PricingResult {
+	return PricingResult{
+		Items:             b.items,
+		BundledItems:      make(ProductCollectionType),
+		SuggestedBundles:  make(ProductCollectionType),
+		UndiscountedTotal: b.undiscountedTotal,
+		optionalBundles:   make(map[BundleOffer]bool),
+	}
+}
+
+// Evaluates every rule once, returning what each rule bundled by rule index.
+func (e *pricingEngine) pricePass(b *basket, result *PricingResult, bundleChoices map[BundleOffer]bool, previous map[int]BundledProduct, stale *staleRules, available func(int, Rule) bool) map[int]BundledProduct {
+	b.discount = 0
+	bundled := make(map[int]BundledProduct)
+
+	// Post-offer rules are evaluated last, against the total after the
+	// discounts of every other rule.
+	for _, afterOffers := range []bool{false, true} {
+		total := b.Total()
+
+		for i, rule := range e.rules {
+			traits := e.traits[i]
+			if traits.postOffer != afterOffers || e.unchangedAndUnapplied(i, stale) || !available(i, rule) {
+				continue
+			}
+
+			var view Basket = b
+			if traits.countsBundles {
+				view = e.withBundledItems(b, previous, i)
+			}
+			if afterOffers {
+				view = &postOfferView{view, total}
+			}
+
+			discount, bp := e.evaluateRule(i, rule, view, stale)
+
+			if bp.count != 0 && bp.code != "" {
+				if mode := traits.mode; mode != MandatoryBundle {
+					offer := BundleOffer{traits.bundleID, bp.code}
+					result.optionalBundles[offer] = true
+					if !bundleAccepted(bundleChoices, offer, mode) {
+						e.addBundled(result.SuggestedBundles, bp)
+						bp = BundledProduct{}
+					}
+				}
+			}
+
+			b.discount += discount
+
+			if discount != 0 || (bp.count != 0 && bp.code != "") {
+				result.AppliedRules = append(result.AppliedRules, createAppliedRule(i, rule, discount, bp))
+			}
+
+			if bp.count != 0 && bp.code != "" {
+				e.addBundled(result.BundledItems, bp)
+				bundled[i] = bp
+			}
+		}
+	}
+
+	sort.Slice(result.AppliedRules, func(i, j int) bool {
+		return result.AppliedRules[i].Index < result.AppliedRules[j].Index
+	})
+
+	return bundled
+}
+
+func (e *pricingEngine) addBundled(bundled ProductCollectionType, bp BundledProduct) {
+	if v, ok := bundled[bp.code]; !ok {
+		if product, ok := e.catalogue[bp.code]; !ok {
+			log.Printf("Failed to find %v in product map. This implies a rule is setup for a product which doesnt exist.\n", bp.code)
+		} else {
+			bundled[bp.code] = &ProductCount{product, bp.count}
+		}
+	} else {
+		v.add(bp.count)
+	}
+}
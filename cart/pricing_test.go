@@ -0,0 +1,119 @@
+package cart
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func scenarioCodes(itemsToAdd []ProductCodeCount) []string {
+	var codes []string
+	for _, pcc := range itemsToAdd {
+		for i := uint16(0); i < pcc.count; i++ {
+			codes = append(codes, pcc.prodCode)
+		}
+	}
+
+	return codes
+}
+
+func Test_Price_WHEN_Scenarios_EXPECT_SameAsCart(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+
+	for _, tt := range scenarioTests {
+		items, err := CreateItems(catalogue, scenarioCodes(tt.itemsToAdd)...)
+		if err != nil {
+			t.Fatalf("%s: Error=%v", tt.name, err)
+		}
+
+		result := Price(catalogue, CreateDefaultRules(), items, tt.promoCodes, PricingContext{})
+
+		if result.Total != tt.expectedCartTotal {
+			t.Errorf("%s: Total=%d, Expected=%d", tt.name, result.Total, tt.expectedCartTotal)
+		}
+
+		if result.UndiscountedTotal-result.Discount != result.Total {
+			t.Errorf("%s: UndiscountedTotal=%d Discount=%d Total=%d", tt.name, result.UndiscountedTotal, result.Discount, result.Total)
+		}
+
+		for _, pcc := range tt.expectedBundledItems {
+			if v, ok := result.BundledItems[pcc.prodCode]; !ok || v.Count() != pcc.count {
+				t.Errorf("%s: BundledItems=%v, Expected %d %s", tt.name, result.BundledItems, pcc.count, pcc.prodCode)
+			}
+		}
+	}
+}
+
+func Test_Price_WHEN_Priced_EXPECT_InputsUnchanged(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	items, _ := CreateItems(catalogue, "ult_small", "ult_small", "ult_small", "ult_medium")
+	promoCodes := []string{"I<3AMAYSIM"}
+	before := items.copy()
+
+	result := Price(catalogue, CreateDefaultRules(), items, promoCodes, PricingContext{})
+	result.Items["ult_small"].count = 1
+
+	if !reflect.DeepEqual(items, before) || !reflect.DeepEqual(promoCodes, []string{"I<3AMAYSIM"}) {
+		t.Errorf("Items=%v PromoCodes=%v, Expected=%v [I<3AMAYSIM]", items, promoCodes, before)
+	}
+}
+
+func Test_Price_WHEN_PricedAt_EXPECT_ScheduledRulesActiveThen(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rules := []Rule{CreateScheduledRule(CreateXForYRule("ult_small", 3, 2), start, start.Add(24*time.Hour))}
+	items, _ := CreateItems(catalogue, "ult_small", "ult_small", "ult_small")
+
+	if result := Price(catalogue, rules, items, nil, PricingContext{At: start.Add(time.Hour)}); result.Total != 2*2490 {
+		t.Errorf("Total=%d, Expected=%d", result.Total, 2*2490)
+	}
+
+	if result := Price(catalogue, rules, items, nil, PricingContext{At: start.Add(-time.Hour)}); result.Total != 3*2490 {
+		t.Errorf("Total=%d, Expected=%d", result.Total, 3*2490)
+	}
+}
+
+func Test_Price_WHEN_GiftSelectedAndBundleDeclined_EXPECT_SameAsCart(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rules := []Rule{
+		CreateGiftRule("large-gift", "ult_large", 1, []string{"1gb", "ult_small"}, 1, ""),
+		CreateBundleModeRule("medium-data", CreateBundleRule("ult_medium", 1, "1gb", 1), DeclinableBundle),
+	}
+
+	c := CreateCart(rules, catalogue)
+	c.Add(catalogue["ult_large"])
+	c.Add(catalogue["ult_medium"])
+	c.SelectGift("large-gift", "ult_small")
+	c.DeclineBundle("medium-data", "1gb")
+
+	result := Price(catalogue, rules, c.Items(), nil, PricingContext{
+		GiftSelections: map[string]string{"large-gift": "ult_small"},
+		BundleChoices:  map[BundleOffer]bool{{"medium-data", "1gb"}: false},
+	})
+
+	if !reflect.DeepEqual(result.BundledItems, c.BundledItems()) || !reflect.DeepEqual(result.SuggestedBundles, c.SuggestedBundles()) {
+		t.Errorf("BundledItems=%v SuggestedBundles=%v, Expected=%v %v",
+			result.BundledItems, result.SuggestedBundles, c.BundledItems(), c.SuggestedBundles())
+	}
+}
+
+func Test_CreateItems_WHEN_UnknownProduct_EXPECT_Error(t *testing.T) {
+	if _, err := CreateItems(CreateDefaultCatalogue(), "ult_small", "ult_huge"); err != ErrUnknownProduct {
+		t.Errorf("Error=%v, Expected=%v", err, ErrUnknownProduct)
+	}
+}
+
+func Test_Price_WHEN_BundlesExceedMaxCount_EXPECT_Clamped(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rules := []Rule{
+		CreateBundleRule("ult_medium", 1, "1gb", 40000),
+		CreateBundleRule("ult_large", 1, "1gb", 40000),
+	}
+	items, _ := CreateItems(catalogue, "ult_medium", "ult_large")
+
+	result := Price(catalogue, rules, items, nil, PricingContext{})
+
+	if v := result.BundledItems["1gb"]; v == nil || v.count != maxProductCount {
+		t.Errorf("BundledItems=%v, Expected %d 1gb", result.BundledItems, maxProductCount)
+	}
+}
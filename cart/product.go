@@ -1,5 +1,12 @@
 package cart
 
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownProduct = errors.New("cart: no product with that code in the catalogue")
+
 // Make it easy to change the price type everywhere if i change my mind.
 type PriceType int32
 
@@ -8,6 +15,23 @@ type ProductCount struct {
 	count   uint16
 }
 
+func (pc *ProductCount) Product() Product {
+	return pc.product
+}
+
+func (pc *ProductCount) Count() uint16 {
+	return pc.count
+}
+
+// Adds units, stopping at the most a count can hold.
+func (pc *ProductCount) add(n uint16) {
+	if int(pc.count)+int(n) > maxProductCount {
+		pc.count = maxProductCount
+	} else {
+		pc.count += n
+	}
+}
+
 // Type representing a collection of products as they
 // would appear in a cart.
 // "product code" => {Product, CartCount}
@@ -18,3 +42,35 @@ type Product struct {
 	Name  string
 	Price PriceType
 }
+
+// Creates a collection of items from product codes, one unit per code. Ie. To
+// price a basket with Price.
+func CreateItems(catalogue Catalogue, prodCodes ...string) (ProductCollectionType, error) {
+	items := make(ProductCollectionType)
+	for _, code := range prodCodes {
+		product, ok := catalogue[code]
+		if !ok {
+			return nil, ErrUnknownProduct
+		}
+
+		if v, ok := items[code]; ok {
+			if v.count == maxProductCount {
+				return nil, fmt.Errorf("cart: more than %d units of %s", maxProductCount, code)
+			}
+			v.count++
+		} else {
+			items[code] = &ProductCount{product, 1}
+		}
+	}
+
+	return items, nil
+}
+
+func (pc ProductCollectionType) copy() ProductCollectionType {
+	c := make(ProductCollectionType, len(pc))
+	for k, v := range pc {
+		c[k] = &ProductCount{v.product, v.count}
+	}
+
+	return c
+}
@@ -0,0 +1,212 @@
+package cart
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Transforms a promo code as entered, so variations of a code match. Ie.
+// "i<3amaysim " and "I <3 AMAYSIM" both match I<3AMAYSIM.
+type PromoNormalizer func(string) string
+
+func (n PromoNormalizer) apply(code string) string {
+	if n == nil {
+		return code
+	}
+
+	return n(code)
+}
+
+// Applies each normalizer in turn.
+func NormalizePromoCodes(normalizers ...PromoNormalizer) PromoNormalizer {
+	return func(code string) string {
+		for _, n := range normalizers {
+			code = n(code)
+		}
+
+		return code
+	}
+}
+
+// Upper cases a promo code.
+func FoldPromoCase(code string) string {
+	return strings.ToUpper(code)
+}
+
+// Removes whitespace, invisible formatting characters (Ie. zero width spaces)
+// and the separators '-', '_' and '.' from a promo code.
+func StripPromoSeparators(code string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.Is(unicode.Cf, r) || r == '-' || r == '_' || r == '.' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// Maps characters which look like ASCII to the ASCII they look like. Ie. The
+// full width forms of a phone keyboard, and Cyrillic and Greek capitals which
+// are indistinguishable from Latin ones.
+func MapPromoConfusables(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '！' && r <= '～' {
+			return r - 0xfee0
+		}
+		if r == '　' {
+			return ' '
+		}
+		if ascii, ok := confusables[r]; ok {
+			return ascii
+		}
+		return r
+	}, code)
+}
+
+var confusables = map[rune]rune{
+	// Cyrillic.
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T', 'У': 'Y', 'Х': 'X', 'І': 'I', 'Ј': 'J', 'Ѕ': 'S',
+	'а': 'a', 'е': 'e', 'о': 'o', 'р': 'p', 'с': 'c', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's',
+	// Greek.
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+	'ο': 'o',
+}
+
+// How a cart accepts promo codes.
+type PromoPolicy struct {
+	Normalize       PromoNormalizer // Applied to codes entered and to the codes rules match. Nil matches codes exactly as entered.
+	MaxCodes        int             // Most codes a cart may hold. Zero is unlimited.
+	ExclusiveGroups [][]string      // Codes which cannot be combined. A cart may hold at most one code of each group.
+
+	// Accepts codes which cannot be combined, and applies whichever of them
+	// give the lowest total, setting the others aside. A cart then holds at
+	// most 8 codes, or MaxCodes if fewer.
+	SelectBest bool
+}
+
+// Confusables mapped, separators stripped and case folded, and at most 3 codes.
+func CreateDefaultPromoPolicy() PromoPolicy {
+	return PromoPolicy{
+		Normalize: NormalizePromoCodes(MapPromoConfusables, StripPromoSeparators, FoldPromoCase),
+		MaxCodes:  3,
+	}
+}
+
+func WithPromoPolicy(policy PromoPolicy) CartOption {
+	return func(c *defaultCart) {
+		c.promoPolicy = policy
+	}
+}
+
+// Returns the normalized code of the group exclusive with code which the cart
+// holds, if any.
+func (p PromoPolicy) exclusiveWith(code string, held map[string]bool) (string, bool) {
+	for _, group := range p.ExclusiveGroups {
+		inGroup := false
+		for _, member := range group {
+			if p.Normalize.apply(member) == code {
+				inGroup = true
+			}
+		}
+
+		if !inGroup {
+			continue
+		}
+
+		for _, member := range group {
+			if m := p.Normalize.apply(member); m != code && held[m] {
+				return m, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+type PromoRejection int
+
+const (
+	EmptyPromoCode PromoRejection = iota
+	TooManyPromoCodes
+	ExclusivePromoCode
+)
+
+// Returned by AddPromoCode when the cart's PromoPolicy rejects a code.
+type PromoError struct {
+	Kind          PromoRejection
+	Code          string // The code as entered.
+	Limit         int    // Set for TooManyPromoCodes.
+	ConflictsWith string // Set for ExclusivePromoCode.
+}
+
+func (e *PromoError) Error() string {
+	switch e.Kind {
+	case EmptyPromoCode:
+		return fmt.Sprintf("cart: promo code %q is empty", e.Code)
+	case TooManyPromoCodes:
+		return fmt.Sprintf("cart: cannot add promo code %s, at most %d codes allowed", e.Code, e.Limit)
+	case ExclusivePromoCode:
+		return fmt.Sprintf("cart: cannot add promo code %s, it cannot be combined with %s", e.Code, e.ConflictsWith)
+	}
+
+	return fmt.Sprintf("cart: cannot add promo code %s", e.Code)
+}
+
+// Returns the normalized code, or why the cart's policy rejects it.
+func (c *defaultCart) checkPromoCode(code string) (string, error) {
+	normalized := c.promoPolicy.Normalize.apply(code)
+	if normalized == "" {
+		return "", &PromoError{Kind: EmptyPromoCode, Code: code}
+	}
+
+	if c.promoCodes[normalized] {
+		return normalized, nil
+	}
+
+	max := c.promoPolicy.MaxCodes
+	if c.promoPolicy.SelectBest && (max <= 0 || max > maxBestPromoCodes) {
+		max = maxBestPromoCodes
+	}
+
+	if max > 0 && len(c.promoCodes) >= max {
+		return "", &PromoError{Kind: TooManyPromoCodes, Code: code, Limit: max}
+	}
+
+	if other, ok := c.promoPolicy.exclusiveWith(normalized, c.promoCodes); ok && !c.promoPolicy.SelectBest {
+		return "", &PromoError{Kind: ExclusivePromoCode, Code: code, ConflictsWith: other}
+	}
+
+	return normalized, nil
+}
+
+// Whether the promo code, once normalized, has been entered and is applied.
+// Ie. Not set aside.
+func (c *defaultCart) HasPromoCode(code string) bool {
+	code = c.promoPolicy.Normalize.apply(code)
+	_, setAside := c.setAside[code]
+	return c.promoCodes[code] && !setAside
+}
+
+// Returns whether a rule declares it depends on the promo code, once
+// normalized.
+func ruleHasPromoCode(rule Rule, code string, normalize PromoNormalizer) bool {
+	for _, c := range promoCodesOf([]Rule{rule}) {
+		if normalize.apply(c) == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the codes normalized, sorted and without duplicates.
+func normalizedCodes(codes []string, normalize PromoNormalizer) []string {
+	normalized := make([]string, 0, len(codes))
+	for _, code := range codes {
+		if n := normalize.apply(code); n != "" {
+			normalized = append(normalized, n)
+		}
+	}
+
+	return sortedCodes(normalized)
+}
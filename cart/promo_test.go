@@ -0,0 +1,119 @@
+package cart
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_PromoPolicy_WHEN_CodeVariations_EXPECT_Normalized(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+
+	for _, code := range []string{"i<3amaysim ", "I <3 AMAYSIM", "i-<3-amaysim", "Ｉ<3ＡＭＡＹＳＩＭ", "I<3АMAYSIM", "I<3AMAY​SIM"} {
+		c := CreateCart(CreateDefaultRules(), catalogue, WithPromoPolicy(CreateDefaultPromoPolicy()))
+		c.Add(catalogue["ult_medium"])
+
+		if err := c.AddPromoCode(code); err != nil {
+			t.Errorf("%q: Error=%v", code, err)
+		}
+
+		if c.Total() != 2691 || !reflect.DeepEqual(c.PromoCodes(), []string{"I<3AMAYSIM"}) {
+			t.Errorf("%q: CartTotal=%d PromoCodes=%v, Expected=2691 [I<3AMAYSIM]", code, c.Total(), c.PromoCodes())
+		}
+
+		if !c.HasPromoCode("i<3amaysim") {
+			t.Errorf("%q: HasPromoCode=false", code)
+		}
+
+		c.RemovePromoCode("I<3amaysim")
+		if c.Total() != 2990 || len(c.PromoCodes()) != 0 {
+			t.Errorf("%q: CartTotal=%d PromoCodes=%v, Expected=2990 []", code, c.Total(), c.PromoCodes())
+		}
+	}
+}
+
+func Test_PromoPolicy_WHEN_RuleCodeNotNormalized_EXPECT_RuleMatches(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart([]Rule{CreatePromoRule("MoreCowbell", 20)}, catalogue, WithPromoPolicy(CreateDefaultPromoPolicy()))
+	c.Add(catalogue["1gb"])
+	c.AddPromoCode("more cowbell")
+
+	if c.Total() != 792 {
+		t.Errorf("CartTotal=%d, Expected=792", c.Total())
+	}
+
+	// Adding products after the code only re-evaluates the rule if it is
+	// indexed by the normalized code.
+	c.Add(catalogue["1gb"])
+
+	if c.Total() != 1584 {
+		t.Errorf("CartTotal=%d, Expected=1584", c.Total())
+	}
+}
+
+func Test_PromoPolicy_WHEN_NoPolicy_EXPECT_ExactMatch(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(CreateDefaultRules(), catalogue)
+	c.Add(catalogue["ult_medium"])
+	c.AddPromoCode("i<3amaysim")
+
+	if c.Total() != 2990 {
+		t.Errorf("CartTotal=%d, Expected=2990", c.Total())
+	}
+}
+
+func Test_PromoPolicy_WHEN_TooManyCodes_EXPECT_PromoRejected(t *testing.T) {
+	var events []Event
+	c := CreateCart(CreateDefaultRules(), CreateDefaultCatalogue(),
+		WithPromoPolicy(PromoPolicy{MaxCodes: 2}),
+		WithListener(ListenerFunc(func(e Event) { events = append(events, e) })))
+
+	c.AddPromoCode("A")
+	c.AddPromoCode("B")
+	events = nil
+
+	// Adding a code already held is not another code.
+	if err := c.AddPromoCode("A"); err != nil {
+		t.Errorf("Error=%v", err)
+	}
+
+	err := c.AddPromoCode("C")
+	if pe, ok := err.(*PromoError); !ok || pe.Kind != TooManyPromoCodes || pe.Limit != 2 {
+		t.Errorf("Error=%v", err)
+	}
+
+	if len(events) != 1 || events[0].Type != PromoRejected || events[0].PromoCode != "C" || events[0].Err != err {
+		t.Errorf("Events=%v", events)
+	}
+
+	if len(c.PromoCodes()) != 2 {
+		t.Errorf("PromoCodes=%v", c.PromoCodes())
+	}
+}
+
+func Test_PromoPolicy_WHEN_ExclusiveCodes_EXPECT_SecondRejected(t *testing.T) {
+	policy := CreateDefaultPromoPolicy()
+	policy.ExclusiveGroups = [][]string{{"Welcome", "I<3AMAYSIM"}}
+	c := CreateCart(CreateDefaultRules(), CreateDefaultCatalogue(), WithPromoPolicy(policy))
+
+	c.AddPromoCode("welcome")
+	c.AddPromoCode("SIMS")
+
+	err := c.AddPromoCode("i<3amaysim")
+	if pe, ok := err.(*PromoError); !ok || pe.Kind != ExclusivePromoCode || pe.ConflictsWith != "WELCOME" {
+		t.Errorf("Error=%v", err)
+	}
+
+	if err := c.AddPromoCode(" - "); err == nil || err.(*PromoError).Kind != EmptyPromoCode {
+		t.Errorf("Error=%v", err)
+	}
+}
+
+func Test_EventSourcedCart_WHEN_PromoRejected_EXPECT_NotRecorded(t *testing.T) {
+	c := CreateEventSourcedCart(CreateDefaultRules(), CreateDefaultCatalogue(), WithPromoPolicy(PromoPolicy{MaxCodes: 1}))
+	c.AddPromoCode("A")
+	c.AddPromoCode("B")
+
+	if log := c.Log(); len(log) != 1 || log[0].PromoCode != "A" {
+		t.Errorf("Log=%v", log)
+	}
+}
@@ -0,0 +1,134 @@
+package cart
+
+import (
+	"fmt"
+	"strings"
+)
+
+type RelationType int
+
+const (
+	Requires RelationType = iota // Code requires at least one of Targets in the cart.
+	Excludes                     // Code cannot be in the cart with any of Targets.
+	Replaces                     // Adding Code replaces one unit of the first of Targets in the cart.
+)
+
+func (t RelationType) String() string {
+	switch t {
+	case Requires:
+		return "requires"
+	case Excludes:
+		return "excludes"
+	case Replaces:
+		return "replaces"
+	}
+
+	return "unknown"
+}
+
+type Relation struct {
+	Type    RelationType
+	Code    string
+	Targets []string
+}
+
+// Relationships between products in a Catalogue.
+type Relations []Relation
+
+func CreateDefaultRelations() Relations {
+	return Relations{
+		// Data-packs are add-ons for an Unlimited SIM.
+		{Requires, "1gb", CreateDefaultCategories()["sim"]},
+	}
+}
+
+func WithRelations(relations Relations) CartOption {
+	return func(c *defaultCart) {
+		c.relations = relations
+	}
+}
+
+// Describes a combination of products which breaks a Relation.
+type RelationError struct {
+	Relation Relation
+}
+
+func (e *RelationError) Error() string {
+	return fmt.Sprintf("cart: %s %s %s", e.Relation.Code, e.Relation.Type, strings.Join(e.Relation.Targets, " or "))
+}
+
+func (c *defaultCart) containsAny(codes []string) (string, bool) {
+	for _, code := range codes {
+		if _, ok := c.products[code]; ok {
+			return code, true
+		}
+	}
+
+	return "", false
+}
+
+// Returns an error if adding the product would create an invalid combination.
+func (c *defaultCart) checkRelations(prodCode string) error {
+	for _, r := range c.relations {
+		switch r.Type {
+		case Requires:
+			if _, ok := c.containsAny(r.Targets); r.Code == prodCode && !ok {
+				return &RelationError{r}
+			}
+		case Excludes:
+			if _, ok := c.containsAny(r.Targets); r.Code == prodCode && ok {
+				return &RelationError{r}
+			}
+
+			if _, ok := c.products[r.Code]; ok && contains(r.Targets, prodCode) {
+				return &RelationError{r}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns the products which adding prodCode would replace.
+func (c *defaultCart) replacements(prodCode string) []Product {
+	var replaced []Product
+	for _, r := range c.relations {
+		if r.Type != Replaces || r.Code != prodCode {
+			continue
+		}
+
+		if code, ok := c.containsAny(r.Targets); ok {
+			replaced = append(replaced, c.products[code].product)
+		}
+	}
+
+	return replaced
+}
+
+// Returns the relations broken by the products in the cart. Ie. A data-pack
+// left behind after the SIM it requires was removed.
+func (c *defaultCart) Violations() []*RelationError {
+	var violations []*RelationError
+	for _, r := range c.relations {
+		if _, ok := c.products[r.Code]; !ok {
+			continue
+		}
+
+		_, ok := c.containsAny(r.Targets)
+		if (r.Type == Requires && !ok) || (r.Type == Excludes && ok) {
+			violations = append(violations, &RelationError{r})
+		}
+	}
+
+	return violations
+}
+
+func contains(codes []string, code string) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,106 @@
+package cart
+
+import (
+	"errors"
+	"testing"
+)
+
+func checkRelationError(t *testing.T, err error, expectedType RelationType) {
+	var re *RelationError
+	if !errors.As(err, &re) {
+		t.Errorf("Error=%v, Expected a RelationError", err)
+	} else if re.Relation.Type != expectedType {
+		t.Errorf("RelationType=%v, Expected=%v", re.Relation.Type, expectedType)
+	}
+}
+
+func Test_Relation_GIVEN_NoSim_WHEN_DataPackAdded_EXPECT_Rejected(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(nil, catalogue, WithRelations(CreateDefaultRelations()))
+
+	checkRelationError(t, c.Add(catalogue["1gb"]), Requires)
+	checkCartContainsNProductsWithCode(t, c, "1gb", 0)
+
+	c.Add(catalogue["ult_small"])
+
+	if err := c.Add(catalogue["1gb"]); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func Test_Relation_WHEN_LastSimRemoved_EXPECT_ViolationFlaggedAndCheckoutRejected(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(nil, catalogue, WithRelations(CreateDefaultRelations()))
+
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["1gb"])
+
+	if len(c.Violations()) != 0 {
+		t.Errorf("Violations=%v, Expected none", c.Violations())
+	}
+
+	c.Remove(catalogue["ult_small"])
+
+	if len(c.Violations()) != 1 {
+		t.Fatalf("Violations=%v, Expected one", c.Violations())
+	}
+
+	_, err := c.Checkout()
+	checkRelationError(t, err, Requires)
+}
+
+func Test_Relation_WHEN_ExcludedProductAdded_EXPECT_RejectedInEitherOrder(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	relations := Relations{{Excludes, "ult_small", []string{"ult_large"}}}
+
+	c := CreateCart(nil, catalogue, WithRelations(relations))
+	c.Add(catalogue["ult_small"])
+	checkRelationError(t, c.Add(catalogue["ult_large"]), Excludes)
+
+	c = CreateCart(nil, catalogue, WithRelations(relations))
+	c.Add(catalogue["ult_large"])
+	checkRelationError(t, c.Add(catalogue["ult_small"]), Excludes)
+}
+
+func Test_Relation_WHEN_ReplacingProductAdded_EXPECT_TargetReplaced(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	relations := Relations{{Replaces, "ult_large", []string{"ult_small", "ult_medium"}}}
+	recorder := &eventRecorder{}
+	c := CreateCart(nil, catalogue, WithRelations(relations), WithListener(recorder))
+
+	c.Add(catalogue["ult_medium"])
+	c.Add(catalogue["ult_medium"])
+	recorder.reset()
+	c.Add(catalogue["ult_large"])
+
+	checkCartContainsNProductsWithCode(t, c, "ult_medium", 1)
+	checkCartContainsNProductsWithCode(t, c, "ult_large", 1)
+
+	if c.Total() != 2990+4490 {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), 2990+4490)
+	}
+
+	if len(recorder.events) != 2 || recorder.events[1].Type != ItemRemoved || recorder.events[1].Product.Code != "ult_medium" {
+		t.Errorf("Events=%v", recorder.events)
+	}
+
+	c.Undo()
+	checkCartContainsNProductsWithCode(t, c, "ult_medium", 2)
+	checkCartContainsNProductsWithCode(t, c, "ult_large", 0)
+}
+
+func Test_Relation_GIVEN_MaxLines_WHEN_ReplacingSimAdded_EXPECT_Allowed(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	relations := Relations{{Replaces, "ult_large", []string{"ult_small"}}}
+	constraints := Constraints{LineProducts: CreateDefaultCategories()["sim"], MaxLines: map[string]int{"": 1}}
+	c := CreateCart(nil, catalogue, WithRelations(relations), WithConstraints(constraints))
+
+	c.Add(catalogue["ult_small"])
+
+	if err := c.Add(catalogue["ult_large"]); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	checkConstraintError(t, c.Add(catalogue["ult_medium"]), MaxLinesExceeded)
+	checkCartContainsNProductsWithCode(t, c, "ult_large", 1)
+}
@@ -1,12 +1,17 @@
 package cart
 
+import (
+	"fmt"
+	"time"
+)
+
 type BundledProduct struct {
 	code  string
 	count uint16
 }
 
 type Rule interface {
-	Evaluate(Cart) (discount PriceType, bundledProduct BundledProduct)
+	Evaluate(Basket) (discount PriceType, bundledProduct BundledProduct)
 }
 
 func CreateDefaultRules() []Rule {
@@ -23,110 +28,215 @@ func CreateDefaultRules() []Rule {
 }
 
 func CreateXForYRule(prodCode string, x, y uint16) Rule {
-	return &xForYRule{prodCode, x, y}
+	return &conditionalRule{
+		fmt.Sprintf("%d for %d on %s", x, y, prodCode),
+		MinQuantity(prodCode, x),
+		PayForYOfEveryX(prodCode, x, y),
+	}
 }
 
 func CreateBulkDiscountRule(prodCode string, countToExceed uint16, discountAbs PriceType) Rule {
-	return &bulkDiscountRule{prodCode, countToExceed, discountAbs}
+	return &conditionalRule{
+		fmt.Sprintf("%d off each %s when buying %d or more", discountAbs, prodCode, countToExceed),
+		MinQuantity(prodCode, countToExceed),
+		DiscountEachUnit(prodCode, discountAbs),
+	}
 }
 
 func CreateBundleRule(buyProdCode string, itemsToBuy uint16, getProdCode string, itemsToGet uint16) Rule {
-	return &bundleRule{buyProdCode, itemsToBuy, getProdCode, itemsToGet}
+	return &conditionalRule{
+		fmt.Sprintf("%d free %s with every %d %s", itemsToGet, getProdCode, itemsToBuy, buyProdCode),
+		MinQuantity(buyProdCode, itemsToBuy),
+		BundleWithEvery(buyProdCode, itemsToBuy, getProdCode, itemsToGet),
+	}
 }
 
 func CreatePromoRule(code string, discountPct int8) Rule {
-	return &promoRule{code, discountPct}
+	return &conditionalRule{
+		fmt.Sprintf("%d%% off cart with promo code %s", discountPct, code),
+		HasPromoCode(code),
+		PercentOffItems(discountPct),
+	}
+}
+
+// Discounts every nth unit of the given products by a percentage. Ie. Second
+// SIM 50% off, or every 4th unit free.
+func CreateNthItemDiscountRule(n uint16, discountPct int8, prodCodes ...string) Rule {
+	return &conditionalRule{
+		"",
+		MinUnits(n, prodCodes...),
+		DiscountEveryNth(n, discountPct, prodCodes...),
+	}
+}
+
+// Makes the cheapest unit of the given products (or of any product if none are
+// given) free when at least minItems of them are bought.
+func CreateCheapestItemFreeRule(minItems uint16, prodCodes ...string) Rule {
+	return &conditionalRule{
+		"",
+		MinUnits(minItems, prodCodes...),
+		CheapestFree(prodCodes...),
+	}
+}
+
+// Sells a set of products at a fixed price, once for each complete set in the
+// cart. Ie. ult_medium + 1gb for $35. The set maps product codes to quantities.
+func CreateFixedPriceBundleRule(set map[string]uint16, price PriceType) Rule {
+	return &conditionalRule{
+		fmt.Sprintf("%s for %d", describeSet(set), price),
+		HasCompleteSet(set),
+		FixedPriceForSets(set, price),
+	}
+}
+
+// Creates a rule which performs the action whenever the condition is satisfied.
+func CreateConditionalRule(condition Condition, action Action) Rule {
+	return &conditionalRule{"", condition, action}
 }
 
 func percentageOfPrice(price PriceType, pct int8) PriceType {
 	return PriceType(float32(price) * (float32(pct) / float32(100)))
 }
 
-type xForYRule struct {
-	prodCode string
-	x        uint16
-	y        uint16
+type conditionalRule struct {
+	description string
+	condition   Condition
+	action      Action
 }
 
-func (r *xForYRule) Evaluate(c Cart) (discount PriceType, bundledProduct BundledProduct) {
-
-	if v, ok := c.Items()[r.prodCode]; !ok {
-		return 0, BundledProduct{}
-	} else {
-		if v.count >= r.x {
-			timesToApplyDiscount := uint16(v.count / r.x)
-			discount := PriceType((r.x-r.y)*timesToApplyDiscount) * v.product.Price
-			return discount, BundledProduct{}
-		}
+func (r *conditionalRule) String() string {
+	if r.description != "" {
+		return r.description
 	}
 
-	return 0, BundledProduct{}
+	return fmt.Sprintf("%v when %v", r.action, r.condition)
 }
 
-type bulkDiscountRule struct {
-	prodCode      string
-	countToExceed uint16
-	discountAbs   PriceType
+func (r *conditionalRule) Dependencies() Dependencies {
+	return mergeDependencies(r.condition, r.action)
 }
 
-func (r *bulkDiscountRule) Evaluate(c Cart) (discount PriceType, bundledProduct BundledProduct) {
-	if v, ok := c.Items()[r.prodCode]; !ok {
-		return 0, BundledProduct{}
-	} else {
-		if v.count >= r.countToExceed {
-			discount := PriceType(v.count) * r.discountAbs
-			return discount, BundledProduct{}
-		}
+func (r *conditionalRule) Explain(c Basket) (PromoReason, bool) {
+	return explainCondition(r.condition, c)
+}
+
+func (r *conditionalRule) Evaluate(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	_, discount, bundledProduct = r.evaluate(c)
+	return discount, bundledProduct
+}
+
+// Evaluates the rule, also returning whether its condition was satisfied.
+func (r *conditionalRule) evaluate(c Basket) (bool, PriceType, BundledProduct) {
+	if !r.condition.Satisfied(c) {
+		return false, 0, BundledProduct{}
 	}
 
-	return 0, BundledProduct{}
+	discount, bp := applyCharged(r.action, c)
+	return true, discount, bp
 }
 
-type promoRule struct {
-	code        string
-	discountPct int8
+// Implemented by rules which decorate another rule.
+type ruleWrapper interface {
+	unwrap() Rule
 }
 
-func (r *promoRule) Evaluate(c Cart) (discount PriceType, bundledProduct BundledProduct) {
-	var cartTotal PriceType = 0
+// Calls f with a rule and then each rule it decorates, until f returns true.
+func walkRule(rule Rule, f func(Rule) bool) bool {
+	for rule != nil {
+		if f(rule) {
+			return true
+		}
 
-	codeFound := false
-	for _, code := range c.PromoCodes() {
-		if code == r.code {
-			codeFound = true
+		w, ok := rule.(ruleWrapper)
+		if !ok {
 			break
 		}
+		rule = w.unwrap()
 	}
 
-	if !codeFound {
-		return 0, BundledProduct{}
-	}
+	return false
+}
+
+// Implemented by rules which are only available for part of the time.
+type ScheduledRule interface {
+	Rule
+	ActiveAt(time.Time) bool
+}
 
-	for _, v := range c.Items() {
-		cartTotal += PriceType(v.count) * v.product.Price
+// A rule is active when every schedule it has, including those of the rules
+// it decorates, is.
+func ruleActiveAt(rule Rule, t time.Time) bool {
+	return !walkRule(rule, func(r Rule) bool {
+		sr, ok := r.(ScheduledRule)
+		return ok && !sr.ActiveAt(t)
+	})
+}
+
+// Makes a rule available from start (inclusive) until end (exclusive).
+// A zero start or end leaves that side of the schedule open.
+func CreateScheduledRule(rule Rule, start, end time.Time) Rule {
+	return &scheduledRule{rule, start, end}
+}
+
+type scheduledRule struct {
+	rule  Rule
+	start time.Time
+	end   time.Time
+}
+
+func (r *scheduledRule) ActiveAt(t time.Time) bool {
+	if !r.start.IsZero() && t.Before(r.start) {
+		return false
 	}
 
-	discount = percentageOfPrice(cartTotal, r.discountPct)
+	return r.end.IsZero() || t.Before(r.end)
+}
+
+func (r *scheduledRule) String() string {
+	return fmt.Sprintf("%v (from %v until %v)", r.rule, r.start, r.end)
+}
+
+func (r *scheduledRule) unwrap() Rule {
+	return r.rule
+}
+
+func (r *scheduledRule) Evaluate(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	return r.rule.Evaluate(c)
+}
 
-	return discount, BundledProduct{}
+// Limits how often a rule may be redeemed. remaining returns how many more
+// times the customer may redeem it, Ie. from a store of past orders. It is
+// called whenever the rule is evaluated, so must be quick, and safe for
+// concurrent use if the rule is shared between carts.
+func CreateUsageLimitedRule(rule Rule, remaining func(Customer) int) Rule {
+	return &usageLimitedRule{rule, remaining}
 }
 
-type bundleRule struct {
-	buyProdCode string
-	itemsToBuy  uint16
-	getProdCode string
-	itemsToGet  uint16
+type usageLimitedRule struct {
+	rule      Rule
+	remaining func(Customer) int
 }
 
-func (r *bundleRule) Evaluate(c Cart) (discount PriceType, bundledProduct BundledProduct) {
-	if v, ok := c.Items()[r.buyProdCode]; !ok {
+func (r *usageLimitedRule) String() string {
+	return fmt.Sprintf("%v (whilst usage limit lasts)", r.rule)
+}
+
+func (r *usageLimitedRule) unwrap() Rule {
+	return r.rule
+}
+
+func (r *usageLimitedRule) Explain(c Basket) (PromoReason, bool) {
+	if r.remaining(c.Customer()) > 0 {
+		return PromoReason{}, false
+	}
+
+	return PromoReason{PromoUsageLimitReached, "the offer has been redeemed as often as it may be"}, true
+}
+
+func (r *usageLimitedRule) Evaluate(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	if r.remaining(c.Customer()) <= 0 {
 		return 0, BundledProduct{}
-	} else {
-		if v.count >= r.itemsToBuy {
-			items := (v.count / r.itemsToBuy) * r.itemsToGet
-			return 0, BundledProduct{r.getProdCode, items}
-		}
 	}
 
-	return 0, BundledProduct{}
+	return r.rule.Evaluate(c)
 }
@@ -1,7 +1,9 @@
 package cart
 
 import (
+	"fmt"
 	"testing"
+	"time"
 )
 
 func compareActualAgainstExpectation(t *testing.T, actualDiscount PriceType, actualBundleProduct BundledProduct, expectedDiscount PriceType, expectedBundleProduct BundledProduct) {
@@ -204,3 +206,152 @@ func Test_PromoRule_WHEN_PromoCodeAdded_EXPECT_DiscountAndNoBundledItems(t *test
 
 	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, expectedDiscount, expectedBundleProduct)
 }
+
+func Test_ScheduledRule_WHEN_OutsideSchedule_EXPECT_Inactive(t *testing.T) {
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC)
+	rule := CreateScheduledRule(CreatePromoRule("MoreCowbell", 20), start, end).(ScheduledRule)
+
+	if rule.ActiveAt(start.Add(-time.Second)) {
+		t.Errorf("Rule active before start.")
+	}
+
+	if !rule.ActiveAt(start) || !rule.ActiveAt(end.Add(-time.Second)) {
+		t.Errorf("Rule inactive within schedule.")
+	}
+
+	if rule.ActiveAt(end) {
+		t.Errorf("Rule active at end.")
+	}
+}
+
+func Test_ScheduledRule_WHEN_WrappedAndExpired_EXPECT_NotApplied(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	clock := createTestClock()
+	expired := CreateScheduledRule(CreatePromoRule("HALF", 50), time.Time{}, clock.t.Add(-time.Hour))
+	items, _ := CreateItems(catalogue, "ult_small")
+
+	for _, rule := range []Rule{
+		expired,
+		CreateCustomerRule(NewCustomers, expired),
+		CreateBundleModeRule("half", expired, DeclinableBundle),
+		CreateUsageLimitedRule(expired, func(Customer) int { return 1 }),
+	} {
+		c := CreateCart([]Rule{rule}, catalogue, WithClock(clock.now))
+		c.Add(catalogue["ult_small"])
+		c.AddPromoCode("HALF")
+
+		if c.Total() != 2490 {
+			t.Errorf("Rule=%v CartTotal=%d, Expected=2490", rule, c.Total())
+		}
+
+		if result := Price(catalogue, []Rule{rule}, items, []string{"HALF"}, PricingContext{At: clock.t}); result.Total != 2490 {
+			t.Errorf("Rule=%v Total=%d, Expected=2490", rule, result.Total)
+		}
+	}
+}
+
+func Test_NthItemDiscountRule_WHEN_SecondSimAdded_EXPECT_CheaperSimDiscounted(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rule := CreateNthItemDiscountRule(2, 50, CreateDefaultCategories()["sim"]...)
+	cart := CreateCart([]Rule{rule}, catalogue)
+
+	cart.Add(catalogue["ult_small"])
+	cart.Add(catalogue["1gb"])
+	actualDiscount, actualBundleProduct := rule.Evaluate(cart)
+	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, 0, BundledProduct{})
+
+	cart.Add(catalogue["ult_large"])
+	actualDiscount, actualBundleProduct = rule.Evaluate(cart)
+	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, 1245, BundledProduct{})
+}
+
+func Test_NthItemDiscountRule_WHEN_EveryFourthUnitFree_EXPECT_DiscountPerFourUnits(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	product := catalogue["ult_small"]
+	rule := CreateNthItemDiscountRule(4, 100, product.Code)
+	cart := CreateCart([]Rule{rule}, catalogue)
+
+	for i := 0; i < 9; i++ {
+		cart.Add(product)
+	}
+	actualDiscount, actualBundleProduct := rule.Evaluate(cart)
+
+	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, product.Price*2, BundledProduct{})
+}
+
+func Test_CheapestItemFreeRule_WHEN_MinItemsBought_EXPECT_CheapestFree(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rule := CreateCheapestItemFreeRule(3)
+	cart := CreateCart([]Rule{rule}, catalogue)
+
+	cart.Add(catalogue["ult_large"])
+	cart.Add(catalogue["ult_medium"])
+	actualDiscount, actualBundleProduct := rule.Evaluate(cart)
+	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, 0, BundledProduct{})
+
+	cart.Add(catalogue["1gb"])
+	actualDiscount, actualBundleProduct = rule.Evaluate(cart)
+	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, 990, BundledProduct{})
+}
+
+func Test_ExpandUnits_GIVEN_EqualPrices_EXPECT_OrderedByProductCode(t *testing.T) {
+	items := ProductCollectionType{
+		"b": &ProductCount{Product{"b", "B", 100}, 1},
+		"a": &ProductCount{Product{"a", "A", 100}, 2},
+		"c": &ProductCount{Product{"c", "C", 300}, 1},
+	}
+	expected := []unit{{"c", 300}, {"a", 100}, {"a", 100}, {"b", 100}}
+
+	for i := 0; i < 10; i++ {
+		units := expandUnits(items, nil)
+		if len(units) != len(expected) {
+			t.Fatalf("Units=%v, Expected=%v", units, expected)
+		}
+
+		for j := range units {
+			if units[j] != expected[j] {
+				t.Fatalf("Units=%v, Expected=%v", units, expected)
+			}
+		}
+	}
+}
+
+func Test_FixedPriceBundleRule_WHEN_CompleteSets_EXPECT_EachSetAtFixedPrice(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rule := CreateFixedPriceBundleRule(map[string]uint16{"ult_medium": 1, "1gb": 1}, 3500)
+	c := CreateCart([]Rule{rule}, catalogue)
+
+	c.Add(catalogue["ult_medium"])
+	c.Add(catalogue["ult_medium"])
+	if c.Total() != 2*2990 {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), 2*2990)
+	}
+
+	c.Add(catalogue["1gb"])
+	if expected := PriceType(3500 + 2990); c.Total() != expected {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), expected)
+	}
+
+	c.Add(catalogue["1gb"])
+	c.Add(catalogue["1gb"])
+	if expected := PriceType(2*3500 + 990); c.Total() != expected {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), expected)
+	}
+
+	if s := fmt.Sprint(rule); s != "1gb + ult_medium for 3500" {
+		t.Errorf("Rule=%s", s)
+	}
+}
+
+func Test_FixedPriceBundleRule_WHEN_SetCheaperThanFixedPrice_EXPECT_NoDiscount(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart([]Rule{CreateFixedPriceBundleRule(map[string]uint16{"ult_small": 2}, 6000)}, catalogue)
+
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+
+	if c.Total() != 2*2490 {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), 2*2490)
+	}
+}
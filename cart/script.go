@@ -0,0 +1,264 @@
+package cart
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	defaultScriptMaxSteps      = 10000
+	defaultScriptMaxStringSize = 1024
+	defaultScriptMaxDepth      = 64
+)
+
+type ScriptLimits struct {
+	MaxSteps      int // Expressions evaluated per run. Zero uses the default.
+	MaxStringSize int // Bytes in a string a script builds. Zero uses the default.
+	MaxDepth      int // Nesting of expressions, Ie. parentheses. Zero uses the default.
+}
+
+// A rule whose discount and bundled product are computed by a script, for
+// offers too bespoke for the built in rules.
+//
+// A script reads the cart through the functions below, and sets its result
+// by assigning to the variables discount, bundle and bundle_count.
+//
+//	count(code)         Units of the product in the cart.
+//	price(code)         Unit price of the product in the cart, or else in the catalogue.
+//	subtotal()          Total of the items in the cart without offers applied.
+//	has_promo(code)     Whether the promo code has been added to the cart.
+//	customer_segment()  The segment of the cart's customer.
+//	is_new_customer()   Whether the cart's customer is new.
+//	min(a, b), max(a, b)
+//
+// For example, half price ult_medium for new customers buying two or more:
+//
+//	n = count("ult_medium")
+//	discount = is_new_customer() && n >= 2 ? n * price("ult_medium") / 2 : 0
+type ScriptRule interface {
+	Rule
+	// Runs the script, returning any error which prevented it completing.
+	// Evaluate treats such errors as the rule not applying.
+	Run(Basket) (PriceType, BundledProduct, error)
+}
+
+// Compiles a script into a rule. Returns an error if the script is invalid.
+func CreateScriptRule(source string, limits ScriptLimits) (ScriptRule, error) {
+	if limits.MaxSteps <= 0 {
+		limits.MaxSteps = defaultScriptMaxSteps
+	}
+	if limits.MaxStringSize <= 0 {
+		limits.MaxStringSize = defaultScriptMaxStringSize
+	}
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = defaultScriptMaxDepth
+	}
+
+	program, err := parseScript(source, limits.MaxDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scriptRule{source, program, limits}, nil
+}
+
+type scriptRule struct {
+	source  string
+	program []assignment
+	limits  ScriptLimits
+}
+
+func (r *scriptRule) String() string {
+	return fmt.Sprintf("script rule (%d statements)", len(r.program))
+}
+
+func (r *scriptRule) Evaluate(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	discount, bundledProduct, err := r.Run(c)
+	if err != nil {
+		return 0, BundledProduct{}
+	}
+
+	return discount, bundledProduct
+}
+
+func (r *scriptRule) Run(c Basket) (PriceType, BundledProduct, error) {
+	env := &scriptEnv{
+		cart:          c,
+		vars:          make(map[string]interface{}),
+		maxSteps:      r.limits.MaxSteps,
+		maxStringSize: r.limits.MaxStringSize,
+	}
+
+	for _, a := range r.program {
+		v, err := a.expr.eval(env)
+		if err != nil {
+			return 0, BundledProduct{}, err
+		}
+		env.vars[a.name] = v
+	}
+
+	discount, err := env.intVar("discount", 0, math.MaxInt32)
+	if err != nil {
+		return 0, BundledProduct{}, err
+	}
+
+	bundleCount, err := env.intVar("bundle_count", 0, maxProductCount)
+	if err != nil {
+		return 0, BundledProduct{}, err
+	}
+
+	bundle, ok := env.vars["bundle"]
+	if !ok || bundleCount == 0 {
+		return PriceType(discount), BundledProduct{}, nil
+	}
+
+	code, ok := bundle.(string)
+	if !ok {
+		return 0, BundledProduct{}, fmt.Errorf("script: bundle must be a product code, not %v", bundle)
+	}
+
+	return PriceType(discount), BundledProduct{code, uint16(bundleCount)}, nil
+}
+
+type scriptEnv struct {
+	cart          Basket
+	vars          map[string]interface{}
+	steps         int
+	maxSteps      int
+	maxStringSize int
+}
+
+func (env *scriptEnv) step() error {
+	env.steps++
+	if env.steps > env.maxSteps {
+		return ErrScriptStepLimit
+	}
+
+	return nil
+}
+
+// Returns an integer result variable, which defaults to zero if unset.
+func (env *scriptEnv) intVar(name string, min, max int64) (int64, error) {
+	v, ok := env.vars[name]
+	if !ok {
+		return 0, nil
+	}
+
+	n, ok := v.(int64)
+	if !ok || n < min || n > max {
+		return 0, fmt.Errorf("script: %s must be a number between %d and %d, not %v", name, min, max, v)
+	}
+
+	return n, nil
+}
+
+type scriptFunc func(env *scriptEnv, args []interface{}) (interface{}, error)
+
+var scriptBuiltins = map[string]scriptFunc{
+	"count": func(env *scriptEnv, args []interface{}) (interface{}, error) {
+		code, err := stringArg("count", args)
+		if err != nil {
+			return nil, err
+		}
+
+		if v, ok := env.cart.Items()[code]; ok {
+			return int64(v.count), nil
+		}
+
+		return int64(0), nil
+	},
+	"price": func(env *scriptEnv, args []interface{}) (interface{}, error) {
+		code, err := stringArg("price", args)
+		if err != nil {
+			return nil, err
+		}
+
+		if v, ok := env.cart.Items()[code]; ok {
+			return int64(v.product.Price), nil
+		}
+
+		if p, ok := env.cart.Catalogue()[code]; ok {
+			return int64(p.Price), nil
+		}
+
+		return int64(0), nil
+	},
+	"subtotal": func(env *scriptEnv, args []interface{}) (interface{}, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("script: subtotal takes no arguments")
+		}
+
+		var total int64
+		for _, v := range env.cart.Items() {
+			total += int64(v.count) * int64(v.product.Price)
+		}
+
+		return total, nil
+	},
+	"has_promo": func(env *scriptEnv, args []interface{}) (interface{}, error) {
+		code, err := stringArg("has_promo", args)
+		if err != nil {
+			return nil, err
+		}
+
+		return env.cart.HasPromoCode(code), nil
+	},
+	"customer_segment": func(env *scriptEnv, args []interface{}) (interface{}, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("script: customer_segment takes no arguments")
+		}
+
+		return env.cart.Customer().Segment, nil
+	},
+	"is_new_customer": func(env *scriptEnv, args []interface{}) (interface{}, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("script: is_new_customer takes no arguments")
+		}
+
+		return env.cart.Customer().IsNew(), nil
+	},
+	"min": func(env *scriptEnv, args []interface{}) (interface{}, error) {
+		a, b, err := intArgs("min", args)
+		if err != nil {
+			return nil, err
+		}
+
+		if a < b {
+			return a, nil
+		}
+		return b, nil
+	},
+	"max": func(env *scriptEnv, args []interface{}) (interface{}, error) {
+		a, b, err := intArgs("max", args)
+		if err != nil {
+			return nil, err
+		}
+
+		if a > b {
+			return a, nil
+		}
+		return b, nil
+	},
+}
+
+func stringArg(name string, args []interface{}) (string, error) {
+	if len(args) == 1 {
+		if s, ok := args[0].(string); ok {
+			return s, nil
+		}
+	}
+
+	return "", fmt.Errorf("script: %s takes a single product or promo code", name)
+}
+
+func intArgs(name string, args []interface{}) (int64, int64, error) {
+	if len(args) == 2 {
+		a, aok := args[0].(int64)
+		b, bok := args[1].(int64)
+		if aok && bok {
+			return a, b, nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("script: %s takes two numbers", name)
+}
@@ -0,0 +1,146 @@
+package cart
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_ScriptRule_WHEN_ScriptComputesDiscount_EXPECT_Discount(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rule, err := CreateScriptRule(`
+		# Half price ult_medium for new customers buying two or more.
+		n = count("ult_medium")
+		discount = is_new_customer() && n >= 2 ? n * price("ult_medium") / 2 : 0
+	`, ScriptLimits{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	cart := CreateCart([]Rule{rule}, catalogue)
+
+	cart.Add(catalogue["ult_medium"])
+	actualDiscount, actualBundleProduct := rule.Evaluate(cart)
+	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, 0, BundledProduct{})
+
+	cart.Add(catalogue["ult_medium"])
+	actualDiscount, actualBundleProduct = rule.Evaluate(cart)
+	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, 2990, BundledProduct{})
+
+	cart.SetCustomer(Customer{ID: "c1", ExistingServices: []string{"ult_small"}})
+	actualDiscount, actualBundleProduct = rule.Evaluate(cart)
+	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, 0, BundledProduct{})
+}
+
+func Test_ScriptRule_WHEN_ScriptBundlesProduct_EXPECT_BundledProductInCart(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rule, err := CreateScriptRule(`bundle = "1gb"; bundle_count = has_promo("DATA") ? min(count("ult_large"), 2) : 0`, ScriptLimits{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	cart := CreateCart([]Rule{rule}, catalogue)
+	for i := 0; i < 3; i++ {
+		cart.Add(catalogue["ult_large"])
+	}
+	cart.AddPromoCode("DATA")
+
+	ua, ue := checkItemsAgainstExpectations(t, cart.BundledItems(), []ProductCodeCount{{"1gb", 2}})
+	if len(ua) > 0 || len(ue) > 0 {
+		t.Errorf("UnexpectedItems=%s, UnmatchedExpectations=%s", ua, ue)
+	}
+}
+
+func Test_ScriptRule_WHEN_PriceNotInCart_EXPECT_CataloguePrice(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rule, _ := CreateScriptRule(`discount = subtotal() > price("ult_large") ? price("1gb") : 0`, ScriptLimits{})
+	cart := CreateCart([]Rule{rule}, catalogue)
+
+	cart.Add(catalogue["ult_medium"])
+	cart.Add(catalogue["ult_medium"])
+
+	actualDiscount, _ := rule.Evaluate(cart)
+	if actualDiscount != 990 {
+		t.Errorf("ActualDiscount: %v ExpectedDiscount: 990", actualDiscount)
+	}
+}
+
+func Test_ScriptRule_WHEN_StepLimitExceeded_EXPECT_ErrorAndNoDiscount(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	source := "discount = 1" + strings.Repeat(" + 1", 50)
+	rule, _ := CreateScriptRule(source, ScriptLimits{MaxSteps: 20})
+	cart := CreateCart(nil, catalogue)
+
+	if _, _, err := rule.Run(cart); err != ErrScriptStepLimit {
+		t.Errorf("Error=%v, Expected=%v", err, ErrScriptStepLimit)
+	}
+
+	actualDiscount, actualBundleProduct := rule.Evaluate(cart)
+	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, 0, BundledProduct{})
+}
+
+func Test_ScriptRule_WHEN_StringSizeLimitExceeded_EXPECT_ErrorAndNoDiscount(t *testing.T) {
+	source := `s = "ab"` + strings.Repeat("\ns = s + s", 40) + "\ndiscount = 1"
+	rule, err := CreateScriptRule(source, ScriptLimits{})
+	if err != nil {
+		t.Fatalf("Error=%v", err)
+	}
+	cart := CreateCart(nil, CreateDefaultCatalogue())
+
+	if _, _, err := rule.Run(cart); err != ErrScriptStringLimit {
+		t.Errorf("Error=%v, Expected=%v", err, ErrScriptStringLimit)
+	}
+
+	actualDiscount, actualBundleProduct := rule.Evaluate(cart)
+	compareActualAgainstExpectation(t, actualDiscount, actualBundleProduct, 0, BundledProduct{})
+}
+
+func Test_ScriptRule_WHEN_NestedTooDeep_EXPECT_ParseError(t *testing.T) {
+	for _, source := range []string{
+		"discount = " + strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100),
+		"discount = " + strings.Repeat("-", 100) + "1",
+	} {
+		if _, err := CreateScriptRule(source, ScriptLimits{}); err == nil {
+			t.Errorf("Expected a parse error for %q", source)
+		}
+	}
+
+	source := "discount = " + strings.Repeat("(", 10) + "1" + strings.Repeat(")", 10)
+	if _, err := CreateScriptRule(source, ScriptLimits{}); err != nil {
+		t.Errorf("Error=%v", err)
+	}
+}
+
+func Test_ScriptRule_WHEN_ScriptInvalid_EXPECT_Errors(t *testing.T) {
+	for _, source := range []string{
+		`discount = `,
+		`discount = (1 + 2`,
+		`discount = "unterminated`,
+		`1 = discount`,
+		`discount = 1 @ 2`,
+	} {
+		if _, err := CreateScriptRule(source, ScriptLimits{}); err == nil {
+			t.Errorf("Expected a parse error for %q", source)
+		}
+	}
+
+	cart := CreateCart(nil, CreateDefaultCatalogue())
+	for _, source := range []string{
+		`discount = unknown`,
+		`discount = nope("x")`,
+		`discount = 1 / 0`,
+		`discount = "ten"`,
+		`discount = -5`,
+		`discount = true + 1`,
+		`bundle = 1; bundle_count = 1`,
+	} {
+		rule, err := CreateScriptRule(source, ScriptLimits{})
+		if err != nil {
+			t.Errorf("Unexpected parse error for %q: %v", source, err)
+			continue
+		}
+
+		if _, _, err := rule.Run(cart); err == nil {
+			t.Errorf("Expected a runtime error for %q", source)
+		}
+	}
+}
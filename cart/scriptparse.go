@@ -0,0 +1,541 @@
+package cart
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The scripting language is deliberately small. A script is a sequence of
+// assignments separated by newlines or semicolons. There are no loops or
+// user defined functions, so every script terminates.
+//
+//	n = count("ult_medium")   # Comments run to the end of the line.
+//	discount = n >= 2 ? price("ult_medium") / 2 : 0
+//
+// Values are integers, strings or booleans. Operators, loosest first:
+//
+//	?:  ||  &&  == !=  < <= > >=  + -  * / %  unary - !
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenEnd           // Newline or semicolon.
+	tokenIdent
+	tokenInt
+	tokenString
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+
+	for i < len(src) {
+		ch := src[i]
+
+		switch {
+		case ch == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case ch == '\n' || ch == ';':
+			tokens = append(tokens, token{tokenEnd, string(ch), i})
+			i++
+		case ch == ' ' || ch == '\t' || ch == '\r':
+			i++
+		case isIdentStart(ch):
+			start := i
+			for i < len(src) && (isIdentStart(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, src[start:i], start})
+		case isDigit(ch):
+			start := i
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenInt, src[start:i], start})
+		case ch == '"':
+			start := i
+			var sb strings.Builder
+			i++
+			for ; i < len(src) && src[i] != '"'; i++ {
+				if src[i] == '\n' {
+					break
+				}
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				sb.WriteByte(src[i])
+			}
+			if i >= len(src) || src[i] != '"' {
+				return nil, fmt.Errorf("script: unterminated string at %d", start)
+			}
+			i++
+			tokens = append(tokens, token{tokenString, sb.String(), start})
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<=", ">=", "+", "-", "*", "/", "%", "<", ">", "!", "(", ")", ",", "?", ":", "="} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("script: unexpected %q at %d", ch, i)
+			}
+			tokens = append(tokens, token{tokenOp, op, i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{tokenEOF, "", len(src)}), nil
+}
+
+func isIdentStart(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+type scriptNode interface {
+	eval(env *scriptEnv) (interface{}, error)
+}
+
+type assignment struct {
+	name string
+	expr scriptNode
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+type variableNode struct {
+	name string
+	pos  int
+}
+
+type unaryNode struct {
+	op      string
+	operand scriptNode
+}
+
+type binaryNode struct {
+	op          string
+	left, right scriptNode
+}
+
+type conditionalNode struct {
+	cond, then, otherwise scriptNode
+}
+
+type callNode struct {
+	name string
+	args []scriptNode
+	pos  int
+}
+
+type scriptParser struct {
+	tokens   []token
+	pos      int
+	depth    int
+	maxDepth int
+}
+
+func parseScript(src string, maxDepth int) ([]assignment, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &scriptParser{tokens: tokens, maxDepth: maxDepth}
+	var program []assignment
+
+	for {
+		for p.peek().kind == tokenEnd {
+			p.next()
+		}
+
+		if p.peek().kind == tokenEOF {
+			return program, nil
+		}
+
+		name := p.next()
+		if name.kind != tokenIdent {
+			return nil, fmt.Errorf("script: expected a variable name at %d", name.pos)
+		}
+
+		if eq := p.next(); eq.kind != tokenOp || eq.text != "=" {
+			return nil, fmt.Errorf("script: expected = at %d", eq.pos)
+		}
+
+		expr, err := p.parseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+
+		if end := p.peek(); end.kind != tokenEnd && end.kind != tokenEOF {
+			return nil, fmt.Errorf("script: unexpected %q at %d", end.text, end.pos)
+		}
+
+		program = append(program, assignment{name.text, expr})
+	}
+}
+
+func (p *scriptParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *scriptParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *scriptParser) expectOp(op string) error {
+	if t := p.next(); t.kind != tokenOp || t.text != op {
+		return fmt.Errorf("script: expected %s at %d", op, t.pos)
+	}
+
+	return nil
+}
+
+var binaryPrecedence = map[string]int{
+	"||": 2,
+	"&&": 3,
+	"==": 4, "!=": 4,
+	"<": 5, "<=": 5, ">": 5, ">=": 5,
+	"+": 6, "-": 6,
+	"*": 7, "/": 7, "%": 7,
+}
+
+// Parses an expression whose operators bind tighter than minPrecedence.
+// Counts a level of nesting, so deeply nested expressions can't exhaust the
+// stack when parsed or evaluated. Paired with leave.
+func (p *scriptParser) enter() error {
+	p.depth++
+	if p.depth > p.maxDepth {
+		return fmt.Errorf("script: expressions nested more than %d deep at %d", p.maxDepth, p.peek().pos)
+	}
+
+	return nil
+}
+
+func (p *scriptParser) leave() {
+	p.depth--
+}
+
+func (p *scriptParser) parseExpr(minPrecedence int) (scriptNode, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		if t.kind != tokenOp {
+			return left, nil
+		}
+
+		if t.text == "?" && minPrecedence <= 1 {
+			p.next()
+			then, err := p.parseExpr(1)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(":"); err != nil {
+				return nil, err
+			}
+			otherwise, err := p.parseExpr(1)
+			if err != nil {
+				return nil, err
+			}
+			left = &conditionalNode{left, then, otherwise}
+			continue
+		}
+
+		precedence, ok := binaryPrecedence[t.text]
+		if !ok || precedence <= minPrecedence {
+			return left, nil
+		}
+
+		p.next()
+		right, err := p.parseExpr(precedence)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{t.text, left, right}
+	}
+}
+
+func (p *scriptParser) parseUnary() (scriptNode, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	t := p.peek()
+	if t.kind == tokenOp && (t.text == "-" || t.text == "!") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &unaryNode{t.text, operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *scriptParser) parsePrimary() (scriptNode, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenInt:
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("script: invalid number %s at %d", t.text, t.pos)
+		}
+		return &literalNode{n}, nil
+	case tokenString:
+		return &literalNode{t.text}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{true}, nil
+		case "false":
+			return &literalNode{false}, nil
+		}
+
+		if next := p.peek(); next.kind != tokenOp || next.text != "(" {
+			return &variableNode{t.text, t.pos}, nil
+		}
+
+		p.next()
+		call := &callNode{name: t.text, pos: t.pos}
+		if next := p.peek(); next.kind == tokenOp && next.text == ")" {
+			p.next()
+			return call, nil
+		}
+
+		for {
+			arg, err := p.parseExpr(0)
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+
+			sep := p.next()
+			if sep.kind == tokenOp && sep.text == ")" {
+				return call, nil
+			}
+			if sep.kind != tokenOp || sep.text != "," {
+				return nil, fmt.Errorf("script: expected , or ) at %d", sep.pos)
+			}
+		}
+	case tokenOp:
+		if t.text == "(" {
+			expr, err := p.parseExpr(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return expr, nil
+		}
+	}
+
+	return nil, fmt.Errorf("script: unexpected %q at %d", t.text, t.pos)
+}
+
+var (
+	ErrScriptStepLimit   = errors.New("script: step limit exceeded")
+	ErrScriptStringLimit = errors.New("script: string size limit exceeded")
+)
+
+func (n *literalNode) eval(env *scriptEnv) (interface{}, error) {
+	if err := env.step(); err != nil {
+		return nil, err
+	}
+
+	return n.value, nil
+}
+
+func (n *variableNode) eval(env *scriptEnv) (interface{}, error) {
+	if err := env.step(); err != nil {
+		return nil, err
+	}
+
+	v, ok := env.vars[n.name]
+	if !ok {
+		return nil, fmt.Errorf("script: undefined variable %s at %d", n.name, n.pos)
+	}
+
+	return v, nil
+}
+
+func (n *unaryNode) eval(env *scriptEnv) (interface{}, error) {
+	if err := env.step(); err != nil {
+		return nil, err
+	}
+
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x := v.(type) {
+	case int64:
+		if n.op == "-" {
+			return -x, nil
+		}
+	case bool:
+		if n.op == "!" {
+			return !x, nil
+		}
+	}
+
+	return nil, fmt.Errorf("script: cannot apply %s to %v", n.op, v)
+}
+
+func (n *binaryNode) eval(env *scriptEnv) (interface{}, error) {
+	if err := env.step(); err != nil {
+		return nil, err
+	}
+
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short circuit the logical operators.
+	if lb, ok := left.(bool); ok && (n.op == "&&" || n.op == "||") {
+		if (n.op == "&&" && !lb) || (n.op == "||" && lb) {
+			return lb, nil
+		}
+	}
+
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	}
+
+	switch l := left.(type) {
+	case int64:
+		r, ok := right.(int64)
+		if !ok {
+			break
+		}
+
+		switch n.op {
+		case "+":
+			return l + r, nil
+		case "-":
+			return l - r, nil
+		case "*":
+			return l * r, nil
+		case "/", "%":
+			if r == 0 {
+				return nil, errors.New("script: division by zero")
+			}
+			if n.op == "/" {
+				return l / r, nil
+			}
+			return l % r, nil
+		case "<":
+			return l < r, nil
+		case "<=":
+			return l <= r, nil
+		case ">":
+			return l > r, nil
+		case ">=":
+			return l >= r, nil
+		}
+	case string:
+		if r, ok := right.(string); ok && n.op == "+" {
+			if len(l)+len(r) > env.maxStringSize {
+				return nil, ErrScriptStringLimit
+			}
+			return l + r, nil
+		}
+	case bool:
+		if r, ok := right.(bool); ok && (n.op == "&&" || n.op == "||") {
+			return r, nil
+		}
+	}
+
+	return nil, fmt.Errorf("script: cannot apply %s to %v and %v", n.op, left, right)
+}
+
+func (n *conditionalNode) eval(env *scriptEnv) (interface{}, error) {
+	if err := env.step(); err != nil {
+		return nil, err
+	}
+
+	v, err := n.cond.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	cond, ok := v.(bool)
+	if !ok {
+		return nil, fmt.Errorf("script: condition %v is not a boolean", v)
+	}
+
+	if cond {
+		return n.then.eval(env)
+	}
+
+	return n.otherwise.eval(env)
+}
+
+func (n *callNode) eval(env *scriptEnv) (interface{}, error) {
+	if err := env.step(); err != nil {
+		return nil, err
+	}
+
+	fn, ok := scriptBuiltins[n.name]
+	if !ok {
+		return nil, fmt.Errorf("script: unknown function %s at %d", n.name, n.pos)
+	}
+
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	return fn(env, args)
+}
@@ -0,0 +1,172 @@
+package cart
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+type SimulationOptions struct {
+	BatchOptions
+	TopChanges int // Baskets to report in BiggestChanges. Zero reports 10.
+}
+
+// How two rule sets compare over a corpus of baskets. Sums are int64 as a
+// corpus may hold more revenue than a PriceType can.
+type SimulationReport struct {
+	Baskets           int
+	BaselineTotal     int64
+	CandidateTotal    int64
+	BaselineDiscount  int64
+	CandidateDiscount int64
+	DiscountDelta     int64        // Candidate less baseline discount. Positive when the candidate costs more.
+	BaselineFirings   []RuleFiring // By index in the baseline rule set.
+	CandidateFirings  []RuleFiring // By index in the candidate rule set.
+	BiggestChanges    []BasketChange
+}
+
+// How often a rule applied over a corpus, and what it gave away.
+type RuleFiring struct {
+	Index        int
+	Description  string
+	Baskets      int // Baskets the rule applied to.
+	Discount     int64
+	BundledUnits int64
+}
+
+type BasketChange struct {
+	ID             string
+	Index          int // Position of the basket in the corpus.
+	BaselineTotal  PriceType
+	CandidateTotal PriceType
+}
+
+// Candidate less baseline total. Negative when the candidate is cheaper for the
+// customer.
+func (bc BasketChange) Delta() PriceType {
+	return bc.CandidateTotal - bc.BaselineTotal
+}
+
+func (bc BasketChange) String() string {
+	return fmt.Sprintf("%s: %d => %d (%+d)", bc.ID, bc.BaselineTotal, bc.CandidateTotal, bc.Delta())
+}
+
+// Prices every basket from the corpus under both rule sets in parallel, and
+// reports how the candidate compares to the baseline. Ie. How much revenue a
+// new rule set would cost, had it been live for the baskets.
+//
+// Returns ctx.Err() if ctx is done before the corpus is exhausted.
+func Simulate(ctx context.Context, catalogue Catalogue, baseline, candidate []Rule, corpus <-chan PricingRequest, opts SimulationOptions) (SimulationReport, error) {
+	top := opts.TopChanges
+	if top <= 0 {
+		top = 10
+	}
+
+	comparisons := make(chan basketComparison, opts.Buffer)
+	baselineTraits, candidateTraits := ruleTraitsOf(baseline), ruleTraitsOf(candidate)
+
+	priceInParallel(ctx, corpus, opts.Workers, func() func(indexedRequest) bool {
+		b, c := createBatchEngine(catalogue, baseline, baselineTraits), createBatchEngine(catalogue, candidate, candidateTraits)
+		return func(req indexedRequest) bool {
+			cmp := basketComparison{
+				baseline:  b.priceBasket(req.Items, req.PromoCodes, req.Context),
+				candidate: c.priceBasket(req.Items, req.PromoCodes, req.Context),
+			}
+			cmp.change = BasketChange{req.ID, req.index, cmp.baseline.Total, cmp.candidate.Total}
+
+			select {
+			case comparisons <- cmp:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+	}, func() {
+		close(comparisons)
+	})
+
+	report := SimulationReport{
+		BaselineFirings:  createRuleFirings(baseline),
+		CandidateFirings: createRuleFirings(candidate),
+	}
+	for cmp := range comparisons {
+		report.add(cmp, top)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return SimulationReport{}, err
+	}
+
+	report.DiscountDelta = report.CandidateDiscount - report.BaselineDiscount
+	report.sortChanges()
+	if len(report.BiggestChanges) > top {
+		report.BiggestChanges = report.BiggestChanges[:top]
+	}
+
+	return report, nil
+}
+
+type basketComparison struct {
+	change              BasketChange
+	baseline, candidate PricingResult
+}
+
+func createRuleFirings(rules []Rule) []RuleFiring {
+	firings := make([]RuleFiring, len(rules))
+	for i, rule := range rules {
+		firings[i] = RuleFiring{Index: i, Description: fmt.Sprint(rule)}
+	}
+
+	return firings
+}
+
+func (r *SimulationReport) add(cmp basketComparison, top int) {
+	r.Baskets++
+	r.BaselineTotal += int64(cmp.baseline.Total)
+	r.CandidateTotal += int64(cmp.candidate.Total)
+	r.BaselineDiscount += int64(cmp.baseline.Discount)
+	r.CandidateDiscount += int64(cmp.candidate.Discount)
+	countFirings(r.BaselineFirings, cmp.baseline)
+	countFirings(r.CandidateFirings, cmp.candidate)
+
+	if cmp.change.Delta() == 0 {
+		return
+	}
+
+	// Only the biggest changes are kept, trimming once there are twice as many
+	// as will be reported.
+	r.BiggestChanges = append(r.BiggestChanges, cmp.change)
+	if len(r.BiggestChanges) >= 2*top {
+		r.sortChanges()
+		r.BiggestChanges = r.BiggestChanges[:top]
+	}
+}
+
+func countFirings(firings []RuleFiring, result PricingResult) {
+	for _, ar := range result.AppliedRules {
+		f := &firings[ar.Index]
+		f.Baskets++
+		f.Discount += int64(ar.Discount)
+		f.BundledUnits += int64(ar.BundledProduct.count)
+	}
+}
+
+// Sorts the changes by size, largest first, ties broken by corpus position so
+// a report is reproducible.
+func (r *SimulationReport) sortChanges() {
+	sort.Slice(r.BiggestChanges, func(i, j int) bool {
+		a, b := abs(int64(r.BiggestChanges[i].Delta())), abs(int64(r.BiggestChanges[j].Delta()))
+		if a != b {
+			return a > b
+		}
+		return r.BiggestChanges[i].Index < r.BiggestChanges[j].Index
+	})
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
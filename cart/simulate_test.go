@@ -0,0 +1,63 @@
+package cart
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_Simulate_WHEN_CandidateAddsOffer_EXPECT_DeltaAndFirings(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	baseline := CreateDefaultRules()
+	candidate := append(CreateDefaultRules(), CreateSpendThresholdRule(10000, UndiscountedSpend, FixedDiscount(1000)))
+	requests := createBatchTestRequests(catalogue, 200)
+
+	report, err := Simulate(context.Background(), catalogue, baseline, candidate, sendRequests(requests), SimulationOptions{TopChanges: 3})
+	if err != nil {
+		t.Fatalf("Error=%v", err)
+	}
+
+	var baselineDiscount, candidateDiscount int64
+	for _, req := range requests {
+		baselineDiscount += int64(Price(catalogue, baseline, req.Items, req.PromoCodes, req.Context).Discount)
+		candidateDiscount += int64(Price(catalogue, candidate, req.Items, req.PromoCodes, req.Context).Discount)
+	}
+
+	if report.Baskets != len(requests) || report.BaselineDiscount != baselineDiscount || report.CandidateDiscount != candidateDiscount {
+		t.Errorf("Baskets=%d BaselineDiscount=%d CandidateDiscount=%d, Expected=%d %d %d",
+			report.Baskets, report.BaselineDiscount, report.CandidateDiscount, len(requests), baselineDiscount, candidateDiscount)
+	}
+
+	// Only the new rule changes anything, so it accounts for the whole delta.
+	added := report.CandidateFirings[len(candidate)-1]
+	if report.DiscountDelta != added.Discount || added.Baskets == 0 || added.Discount != int64(added.Baskets)*1000 {
+		t.Errorf("DiscountDelta=%d Firing=%+v", report.DiscountDelta, added)
+	}
+
+	for i, f := range report.BaselineFirings {
+		if f != report.CandidateFirings[i] {
+			t.Errorf("BaselineFiring=%+v, CandidateFiring=%+v", f, report.CandidateFirings[i])
+		}
+	}
+
+	if len(report.BiggestChanges) != 3 {
+		t.Fatalf("BiggestChanges=%v", report.BiggestChanges)
+	}
+
+	// Every change is 1000 off, so ties leave the first baskets in the corpus.
+	for i, change := range report.BiggestChanges {
+		if change.Delta() != -1000 || (i > 0 && change.Index < report.BiggestChanges[i-1].Index) {
+			t.Errorf("BiggestChanges=%v", report.BiggestChanges)
+		}
+	}
+}
+
+func Test_Simulate_WHEN_Cancelled_EXPECT_Error(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	corpus := make(chan PricingRequest)
+	if _, err := Simulate(ctx, catalogue, CreateDefaultRules(), nil, corpus, SimulationOptions{}); err != context.Canceled {
+		t.Errorf("Error=%v, Expected=%v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,214 @@
+package cart
+
+import (
+	"fmt"
+	"strings"
+)
+
+type SpendBasis int
+
+const (
+	UndiscountedSpend SpendBasis = iota // Spend before any offers are applied.
+	PostOfferSpend                      // Spend after the discounts of rules which are not post-offer rules.
+)
+
+func (b SpendBasis) String() string {
+	switch b {
+	case UndiscountedSpend:
+		return "before offers"
+	case PostOfferSpend:
+		return "after offers"
+	}
+
+	return "unknown"
+}
+
+// Creates a rule granting the reward when the spend on the given products (or
+// on the whole cart if none are given) reaches the threshold.
+// Ie. Spend $100 and get $10 off, or spend $80 and get a free 1gb.
+func CreateSpendThresholdRule(threshold PriceType, basis SpendBasis, reward Action, prodCodes ...string) Rule {
+	rule := &conditionalRule{
+		fmt.Sprintf("%v when spending %d%s %v", reward, threshold, describeSpendProducts(prodCodes), basis),
+		MinSpend(threshold, basis, prodCodes...),
+		reward,
+	}
+
+	if basis == PostOfferSpend {
+		return CreatePostOfferRule(rule)
+	}
+
+	return rule
+}
+
+// Satisfied when the spend on the given products (or on the whole cart if
+// none are given) is at least amount.
+func MinSpend(amount PriceType, basis SpendBasis, prodCodes ...string) Condition {
+	return &minSpendCondition{amount, basis, prodCodes}
+}
+
+// Discounts the cart by a fixed amount.
+func FixedDiscount(amount PriceType) Action {
+	return &fixedDiscountAction{amount}
+}
+
+// Discounts the spend on the given products (or on the whole cart if none are
+// given) by a percentage.
+func PercentOffSpend(discountPct int8, basis SpendBasis, prodCodes ...string) Action {
+	return &percentOffSpendAction{discountPct, basis, prodCodes}
+}
+
+// Bundles count units of the product.
+func FreeProduct(prodCode string, count uint16) Action {
+	return &freeProductAction{prodCode, count}
+}
+
+// Evaluates a rule after every other rule, so it sees the cart total with
+// their discounts applied. Post-offer rules do not see each other's discounts.
+func CreatePostOfferRule(rule Rule) Rule {
+	return &postOfferRule{rule}
+}
+
+type postOfferRule struct {
+	rule Rule
+}
+
+func (r *postOfferRule) String() string {
+	return fmt.Sprint(r.rule)
+}
+
+func (r *postOfferRule) unwrap() Rule {
+	return r.rule
+}
+
+func (r *postOfferRule) Evaluate(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	return r.rule.Evaluate(c)
+}
+
+func isPostOffer(rule Rule) bool {
+	return walkRule(rule, func(r Rule) bool {
+		_, ok := r.(*postOfferRule)
+		return ok
+	})
+}
+
+// A view of a cart whose total is fixed at what it was before the post-offer
+// rules were evaluated.
+type postOfferView struct {
+	Basket
+	total PriceType
+}
+
+func (v *postOfferView) Total() PriceType {
+	return v.total
+}
+
+func (v *postOfferView) charged() Basket {
+	return &postOfferView{chargedItems(v.Basket), v.total}
+}
+
+// Returns the spend on the given products, or on the whole cart if none are
+// given. Discounts are not attributed to products, so the post-offer spend on
+// some products is their share of the discounted total in proportion to
+// their undiscounted price.
+func spend(c Basket, basis SpendBasis, prodCodes []string) PriceType {
+	var subtotal, restricted int64
+	for code, v := range c.Items() {
+		amount := int64(v.count) * int64(v.product.Price)
+		subtotal += amount
+		if len(prodCodes) == 0 || contains(prodCodes, code) {
+			restricted += amount
+		}
+	}
+
+	if basis == UndiscountedSpend || subtotal == 0 {
+		return PriceType(restricted)
+	}
+
+	return PriceType(restricted * int64(c.Total()) / subtotal)
+}
+
+func describeSpendProducts(prodCodes []string) string {
+	if len(prodCodes) == 0 {
+		return ""
+	}
+
+	return " on " + strings.Join(prodCodes, ", ")
+}
+
+type minSpendCondition struct {
+	amount    PriceType
+	basis     SpendBasis
+	prodCodes []string
+}
+
+func (c *minSpendCondition) String() string {
+	return fmt.Sprintf("spend%s of at least %d %v", describeSpendProducts(c.prodCodes), c.amount, c.basis)
+}
+
+func (c *minSpendCondition) Dependencies() Dependencies {
+	return spendDependencies(c.basis, c.prodCodes)
+}
+
+func (c *minSpendCondition) Explain(cart Basket) (PromoReason, bool) {
+	shortfall := c.amount - spend(cart, c.basis, c.prodCodes)
+	if shortfall <= 0 {
+		return PromoReason{}, false
+	}
+
+	return PromoReason{PromoMinSpendNotMet, fmt.Sprintf("spend %d more%s %v", shortfall, describeSpendProducts(c.prodCodes), c.basis)}, true
+}
+
+func (c *minSpendCondition) Satisfied(cart Basket) bool {
+	return spend(cart, c.basis, c.prodCodes) >= c.amount
+}
+
+type fixedDiscountAction struct {
+	amount PriceType
+}
+
+func (a *fixedDiscountAction) String() string {
+	return fmt.Sprintf("%d off cart", a.amount)
+}
+
+func (a *fixedDiscountAction) Dependencies() Dependencies {
+	return Dependencies{}
+}
+
+func (a *fixedDiscountAction) Apply(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	return a.amount, BundledProduct{}
+}
+
+type percentOffSpendAction struct {
+	discountPct int8
+	basis       SpendBasis
+	prodCodes   []string
+}
+
+func (a *percentOffSpendAction) String() string {
+	return fmt.Sprintf("%d%% off spend%s %v", a.discountPct, describeSpendProducts(a.prodCodes), a.basis)
+}
+
+func (a *percentOffSpendAction) Dependencies() Dependencies {
+	return spendDependencies(a.basis, a.prodCodes)
+}
+
+func (a *percentOffSpendAction) Apply(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	return percentageOfPrice(spend(c, a.basis, a.prodCodes), a.discountPct), BundledProduct{}
+}
+
+type freeProductAction struct {
+	prodCode string
+	count    uint16
+}
+
+func (a *freeProductAction) String() string {
+	return fmt.Sprintf("%d free %s", a.count, a.prodCode)
+}
+
+func (a *freeProductAction) Dependencies() Dependencies {
+	return Dependencies{}
+}
+
+func (a *freeProductAction) Apply(c Basket) (discount PriceType, bundledProduct BundledProduct) {
+	return 0, BundledProduct{a.prodCode, a.count}
+}
@@ -0,0 +1,98 @@
+package cart
+
+import (
+	"testing"
+)
+
+func Test_SpendThresholdRule_WHEN_ThresholdReached_EXPECT_FixedDiscount(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart([]Rule{CreateSpendThresholdRule(10000, UndiscountedSpend, FixedDiscount(1000))}, catalogue)
+
+	for i := 0; i < 4; i++ {
+		c.Add(catalogue["ult_small"])
+	}
+
+	if c.Total() != 4*2490 {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), 4*2490)
+	}
+
+	c.Add(catalogue["ult_small"])
+
+	if expected := PriceType(5*2490 - 1000); c.Total() != expected {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), expected)
+	}
+}
+
+func Test_SpendThresholdRule_WHEN_PostOfferSpendBelowThreshold_EXPECT_NoDiscount(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	postOffer := CreateCart([]Rule{
+		CreateSpendThresholdRule(10000, PostOfferSpend, FixedDiscount(1000)),
+		CreateXForYRule("ult_small", 3, 2),
+	}, catalogue)
+	undiscounted := CreateCart([]Rule{
+		CreateSpendThresholdRule(10000, UndiscountedSpend, FixedDiscount(1000)),
+		CreateXForYRule("ult_small", 3, 2),
+	}, catalogue)
+
+	for i := 0; i < 5; i++ {
+		postOffer.Add(catalogue["ult_small"])
+		undiscounted.Add(catalogue["ult_small"])
+	}
+
+	// The post-offer rule sees the 3 for 2 despite being first in the rule set.
+	if expected := PriceType(4 * 2490); postOffer.Total() != expected {
+		t.Errorf("CartTotal=%d, Expected=%d", postOffer.Total(), expected)
+	}
+
+	if expected := PriceType(4*2490 - 1000); undiscounted.Total() != expected {
+		t.Errorf("CartTotal=%d, Expected=%d", undiscounted.Total(), expected)
+	}
+
+	postOffer.Add(catalogue["ult_small"])
+	postOffer.Add(catalogue["ult_small"])
+	applied := postOffer.(*defaultCart).appliedRules
+	if len(applied) != 2 || applied[0].Index != 0 || applied[1].Index != 1 {
+		t.Errorf("AppliedRules=%v", applied)
+	}
+}
+
+func Test_SpendThresholdRule_WHEN_RestrictedToCategory_EXPECT_OnlyCategoryCounts(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	sims := CreateDefaultCategories()["sim"]
+	c := CreateCart([]Rule{CreateSpendThresholdRule(8000, UndiscountedSpend, FreeProduct("1gb", 1), sims...)}, catalogue)
+
+	for i := 0; i < 9; i++ {
+		c.Add(catalogue["1gb"])
+	}
+
+	if len(c.BundledItems()) != 0 {
+		t.Errorf("BundledItems=%v", c.BundledItems())
+	}
+
+	c.Add(catalogue["ult_large"])
+	c.Add(catalogue["ult_large"])
+
+	checkCartContainsNBundledProductsWithCode(t, c, "1gb", 1)
+}
+
+func Test_Spend_WHEN_PostOffer_EXPECT_DiscountSharedInProportion(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(CreateDefaultRules(), catalogue)
+
+	for i := 0; i < 3; i++ {
+		c.Add(catalogue["ult_small"])
+	}
+	c.Add(catalogue["ult_large"])
+
+	if s := spend(c, UndiscountedSpend, []string{"ult_large"}); s != 4490 {
+		t.Errorf("Spend=%d, Expected=4490", s)
+	}
+
+	if s := spend(c, PostOfferSpend, nil); s != 2*2490+4490 {
+		t.Errorf("Spend=%d, Expected=%d", s, 2*2490+4490)
+	}
+
+	if s := spend(c, PostOfferSpend, []string{"ult_large"}); s != 4490*(2*2490+4490)/(3*2490+4490) {
+		t.Errorf("Spend=%d, Expected=%d", s, 4490*(2*2490+4490)/(3*2490+4490))
+	}
+}
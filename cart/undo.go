@@ -0,0 +1,135 @@
+package cart
+
+import (
+	"time"
+)
+
+const defaultUndoLimit = 20
+
+// Sets how many interactions can be undone. Zero disables undo.
+func WithUndoLimit(limit int) CartOption {
+	return func(c *defaultCart) {
+		c.undoLimit = limit
+	}
+}
+
+// The state of a cart that is not derived from its rules.
+type cartSnapshot struct {
+	products          ProductCollectionType
+	lines             []*cartLine
+	promoCodes        map[string]bool
+	undiscountedTotal PriceType
+	priceLocks        map[string]time.Time
+	ruleLocks         map[int]time.Time
+	giftSelections    map[string]string
+	bundleChoices     map[BundleOffer]bool
+}
+
+func (c *defaultCart) snapshot() cartSnapshot {
+	s := cartSnapshot{
+		products:          c.products.copy(),
+		lines:             copyLines(c.lines),
+		promoCodes:        make(map[string]bool, len(c.promoCodes)),
+		undiscountedTotal: c.undiscountedTotal,
+		priceLocks:        make(map[string]time.Time, len(c.priceLocks)),
+		ruleLocks:         make(map[int]time.Time, len(c.ruleLocks)),
+		giftSelections:    make(map[string]string, len(c.giftSelections)),
+		bundleChoices:     make(map[BundleOffer]bool, len(c.bundleChoices)),
+	}
+
+	for k, v := range c.promoCodes {
+		s.promoCodes[k] = v
+	}
+
+	for k, v := range c.priceLocks {
+		s.priceLocks[k] = v
+	}
+
+	for k, v := range c.ruleLocks {
+		s.ruleLocks[k] = v
+	}
+
+	for k, v := range c.giftSelections {
+		s.giftSelections[k] = v
+	}
+
+	for k, v := range c.bundleChoices {
+		s.bundleChoices[k] = v
+	}
+
+	return s
+}
+
+func (c *defaultCart) restore(s cartSnapshot) {
+	c.products = s.products
+	c.lines = s.lines
+	c.promoCodes = s.promoCodes
+	c.undiscountedTotal = s.undiscountedTotal
+	c.priceLocks = s.priceLocks
+	c.ruleLocks = s.ruleLocks
+	c.giftSelections = s.giftSelections
+	c.bundleChoices = s.bundleChoices
+	c.engine.invalidate()
+}
+
+// Records the current state so the interaction about to happen can be undone.
+func (c *defaultCart) checkpoint() {
+	if c.undoLimit <= 0 {
+		return
+	}
+
+	c.saveUndo(c.snapshot())
+}
+
+// Records a snapshot taken before the interaction in progress so it can be undone.
+func (c *defaultCart) saveUndo(s cartSnapshot) {
+	if c.undoLimit <= 0 {
+		return
+	}
+
+	c.pushUndo(s)
+	c.redoStack = nil
+}
+
+func (c *defaultCart) pushUndo(s cartSnapshot) {
+	c.undoStack = append(c.undoStack, s)
+	if len(c.undoStack) > c.undoLimit {
+		c.undoStack = c.undoStack[len(c.undoStack)-c.undoLimit:]
+	}
+}
+
+// Reverts the most recent interaction which changed the cart. Returns false if
+// there is nothing to undo.
+func (c *defaultCart) Undo() bool {
+	if len(c.undoStack) == 0 {
+		return false
+	}
+
+	before := c.appliedRules
+	c.redoStack = append(c.redoStack, c.snapshot())
+	c.restore(c.undoStack[len(c.undoStack)-1])
+	c.undoStack = c.undoStack[:len(c.undoStack)-1]
+	c.expireLocks()
+	c.evaluateRules()
+	c.emit(before, Event{Type: Undone})
+
+	return true
+}
+
+// Re-applies the most recently undone interaction. Returns false if there is
+// nothing to redo.
+func (c *defaultCart) Redo() bool {
+	if len(c.redoStack) == 0 {
+		return false
+	}
+
+	before := c.appliedRules
+	c.pushUndo(c.snapshot())
+	c.restore(c.redoStack[len(c.redoStack)-1])
+	c.redoStack = c.redoStack[:len(c.redoStack)-1]
+	c.expireLocks()
+	c.evaluateRules()
+	c.emit(before, Event{Type: Redone})
+
+	return true
+}
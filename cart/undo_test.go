@@ -0,0 +1,107 @@
+package cart
+
+import (
+	"testing"
+)
+
+func Test_Undo_WHEN_ClearUndone_EXPECT_ProductsBundlesAndPromoCodesRestored(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(CreateDefaultRules(), catalogue)
+
+	c.Add(catalogue["ult_medium"])
+	c.Add(catalogue["ult_small"])
+	c.AddPromoCode("I<3AMAYSIM")
+	expectedTotal := c.Total()
+
+	c.Clear()
+
+	if !c.Undo() {
+		t.Fatalf("Undo returned false.")
+	}
+
+	checkCartContainsNProductsWithCode(t, c, "ult_medium", 1)
+	checkCartContainsNProductsWithCode(t, c, "ult_small", 1)
+
+	if len(c.PromoCodes()) != 1 || len(c.BundledItems()) != 1 {
+		t.Errorf("PromoCodes=%v BundledItems=%v", c.PromoCodes(), c.BundledItems())
+	}
+
+	if c.Total() != expectedTotal {
+		t.Errorf("CartTotal=%d, Expected=%d", c.Total(), expectedTotal)
+	}
+}
+
+func Test_Undo_WHEN_UndoneThenRedone_EXPECT_RulesReEvaluated(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(CreateDefaultRules(), catalogue)
+
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+
+	c.Undo()
+	if c.Total() != 2*2490 {
+		t.Errorf("After undo CartTotal=%d, Expected=%d", c.Total(), 2*2490)
+	}
+
+	c.Redo()
+	if c.Total() != 2*2490 {
+		t.Errorf("After redo CartTotal=%d, Expected=%d", c.Total(), 2*2490)
+	}
+	checkCartContainsNProductsWithCode(t, c, "ult_small", 3)
+
+	if c.Redo() {
+		t.Errorf("Redo with nothing to redo returned true.")
+	}
+}
+
+func Test_Undo_WHEN_NewInteractionAfterUndo_EXPECT_RedoDiscarded(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(nil, catalogue)
+
+	c.Add(catalogue["ult_small"])
+	c.Undo()
+	c.Add(catalogue["ult_large"])
+
+	if c.Redo() {
+		t.Errorf("Redo returned true after a new interaction.")
+	}
+	checkCartContainsNProductsWithCode(t, c, "ult_small", 0)
+}
+
+func Test_Undo_GIVEN_UndoLimit_WHEN_MoreInteractions_EXPECT_HistoryBounded(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(nil, catalogue, WithUndoLimit(2))
+
+	for i := 0; i < 5; i++ {
+		c.Add(catalogue["ult_small"])
+	}
+
+	undone := 0
+	for c.Undo() {
+		undone++
+	}
+
+	if undone != 2 {
+		t.Errorf("Undone=%d, Expected=2", undone)
+	}
+	checkCartContainsNProductsWithCode(t, c, "ult_small", 3)
+}
+
+func Test_Undo_GIVEN_EventSourcedCart_WHEN_Undone_EXPECT_ReplayMatches(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateEventSourcedCart(CreateDefaultRules(), catalogue)
+
+	c.Add(catalogue["ult_small"])
+	c.Clear()
+	c.Undo()
+	c.Undo()
+	c.Redo()
+
+	r := c.Rebuild(len(c.Log()), CreateDefaultRules(), catalogue)
+	checkCartContainsNProductsWithCode(t, r, "ult_small", 1)
+
+	if r.Total() != c.Total() {
+		t.Errorf("ReplayedTotal=%d CartTotal=%d", r.Total(), c.Total())
+	}
+}
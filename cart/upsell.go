@@ -0,0 +1,302 @@
+package cart
+
+import (
+	"fmt"
+	"sort"
+)
+
+// The most units of a product probed for when looking for upsell hints.
+const maxUpsellUnits = 5
+
+type UpsellKind int
+
+const (
+	AddProductHint     UpsellKind = iota // Add Count units of ProdCode.
+	EnterPromoCodeHint                   // Enter PromoCode.
+	SpendMoreHint                        // Spend Amount more, Ie. on Count units of ProdCode.
+)
+
+func (k UpsellKind) String() string {
+	switch k {
+	case AddProductHint:
+		return "add product"
+	case EnterPromoCodeHint:
+		return "enter promo code"
+	case SpendMoreHint:
+		return "spend more"
+	}
+
+	return "unknown"
+}
+
+// An offer the cart is close to but does not qualify for, and the change which
+// would unlock it.
+type UpsellHint struct {
+	Kind      UpsellKind
+	ProdCode  string
+	Count     uint16
+	PromoCode string
+	Amount    PriceType
+	Saving    PriceType     // Discount, plus the catalogue price of bundled products, of the rules unlocked.
+	Rules     []AppliedRule // The rules unlocked, as they would apply.
+}
+
+func (h UpsellHint) String() string {
+	switch h.Kind {
+	case AddProductHint:
+		return fmt.Sprintf("add %d %s to save %d", h.Count, h.ProdCode, h.Saving)
+	case EnterPromoCodeHint:
+		return fmt.Sprintf("enter promo code %s to save %d", h.PromoCode, h.Saving)
+	case SpendMoreHint:
+		return fmt.Sprintf("spend %d more to save %d", h.Amount, h.Saving)
+	}
+
+	return "unknown"
+}
+
+// Lists the offers which do not apply to the cart but would after a small
+// change, found by pricing the cart with each change against the rules:
+//   - Adding up to 5 units of a catalogue product, which the cart's
+//     constraints and relations allow. Only the fewest units which unlock
+//     something are suggested.
+//   - Entering a promo code a rule depends on (see Dependent).
+//   - Spending enough to reach the threshold of a MinSpend condition.
+//
+// Only rules which don't apply now but would after the change are counted,
+// so a change which merely adds to an existing discount is not suggested.
+// Hints are ordered by saving, largest first.
+func (c *defaultCart) UpsellHints() []UpsellHint {
+	p := c.createUpsellProbe()
+	var hints []UpsellHint
+
+	codes := make([]string, 0, len(c.catalogue))
+	for code := range c.catalogue {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		for n := 1; n <= maxUpsellUnits && c.canAdd(code, n); n++ {
+			if hint, ok := p.probe(p.withUnits(code, uint16(n)), c.PromoCodes(), nil); ok {
+				hint.Kind, hint.ProdCode, hint.Count = AddProductHint, code, uint16(n)
+				hints = append(hints, hint)
+				break
+			}
+		}
+	}
+
+	for _, code := range promoCodesOf(c.rules) {
+		// The code must be one the cart would accept and apply alongside
+		// the codes it holds.
+		normalized, err := c.checkPromoCode(code)
+		if _, exclusive := c.promoPolicy.exclusiveWith(normalized, c.promoCodes); err != nil || exclusive || c.promoCodes[normalized] {
+			continue
+		}
+
+		if hint, ok := p.probe(c.products, append(c.PromoCodes(), code), nil); ok {
+			hint.Kind, hint.PromoCode = EnterPromoCodeHint, code
+			hints = append(hints, hint)
+		}
+	}
+
+	for i, rule := range c.rules {
+		if hint, ok := p.probeSpend(i, rule); ok {
+			hints = append(hints, hint)
+		}
+	}
+
+	sort.SliceStable(hints, func(i, j int) bool {
+		return hints[i].Saving > hints[j].Saving
+	})
+
+	return hints
+}
+
+// Prices changes to a cart against the same rules, and compares them to the
+// cart as it is.
+type upsellProbe struct {
+	cart     *defaultCart
+	engine   *pricingEngine
+	ctx      PricingContext
+	baseline PricingResult
+	applied  map[int]bool // Rules which apply to the cart as it is.
+}
+
+func (c *defaultCart) createUpsellProbe() *upsellProbe {
+	p := &upsellProbe{
+		cart:   c,
+		engine: createBatchEngine(c.catalogue, c.rules, c.engine.traits),
+		ctx:    PricingContext{Customer: c.customer, GiftSelections: c.giftSelections, BundleChoices: c.bundleChoices, NormalizePromo: c.promoPolicy.Normalize},
+	}
+
+	p.baseline = p.price(c.products, c.PromoCodes())
+	p.applied = make(map[int]bool)
+	for _, ar := range p.baseline.AppliedRules {
+		p.applied[ar.Index] = true
+	}
+
+	return p
+}
+
+func (p *upsellProbe) price(items ProductCollectionType, promoCodes []string) PricingResult {
+	now := p.cart.now()
+	return p.engine.priceWith(items, promoCodes, p.ctx, func(i int, rule Rule) bool {
+		return p.cart.ruleAvailable(i, rule, now)
+	})
+}
+
+// Returns the cart's items with n more units of a product.
+func (p *upsellProbe) withUnits(prodCode string, n uint16) ProductCollectionType {
+	items := p.cart.products.copy()
+	if v, ok := items[prodCode]; ok {
+		v.add(n)
+	} else {
+		items[prodCode] = &ProductCount{p.cart.catalogue[prodCode], n}
+	}
+
+	return items
+}
+
+// Prices a change, returning a hint if it unlocks any rule. If only is not nil,
+// only the rules it includes are counted.
+func (p *upsellProbe) probe(items ProductCollectionType, promoCodes []string, only map[int]bool) (UpsellHint, bool) {
+	var hint UpsellHint
+	for _, ar := range p.price(items, promoCodes).AppliedRules {
+		if p.applied[ar.Index] || (only != nil && !only[ar.Index]) {
+			continue
+		}
+
+		hint.Rules = append(hint.Rules, ar)
+		hint.Saving += ar.Discount
+		if product, ok := p.cart.catalogue[ar.BundledProduct.code]; ok {
+			hint.Saving += PriceType(ar.BundledProduct.count) * product.Price
+		}
+	}
+
+	return hint, len(hint.Rules) > 0
+}
+
+// Returns a hint for a rule which doesn't apply as the spend falls short of
+// one of its MinSpend conditions. The shortfall is probed by adding units of
+// the cheapest product the spend counts.
+func (p *upsellProbe) probeSpend(i int, rule Rule) (UpsellHint, bool) {
+	if p.applied[i] {
+		return UpsellHint{}, false
+	}
+
+	for _, cond := range minSpendConditionsOf(rule) {
+		var view Basket = p.cart.basket()
+		if cond.basis == PostOfferSpend {
+			view = &postOfferView{view, p.baseline.UndiscountedTotal - p.discountBeforePostOffer()}
+		}
+
+		shortfall := cond.amount - spend(view, cond.basis, cond.prodCodes)
+		if shortfall <= 0 {
+			continue
+		}
+
+		product, ok := p.cheapest(cond.prodCodes)
+		if !ok {
+			continue
+		}
+
+		n := (int(shortfall) + int(product.Price) - 1) / int(product.Price)
+		if !p.cart.canAdd(product.Code, n) {
+			continue
+		}
+
+		if hint, ok := p.probe(p.withUnits(product.Code, uint16(n)), p.cart.PromoCodes(), map[int]bool{i: true}); ok {
+			hint.Kind, hint.ProdCode, hint.Count, hint.Amount = SpendMoreHint, product.Code, uint16(n), shortfall
+			return hint, true
+		}
+	}
+
+	return UpsellHint{}, false
+}
+
+// Returns the discount of the baseline rules which are not post-offer rules,
+// being the discount post-offer rules see.
+func (p *upsellProbe) discountBeforePostOffer() PriceType {
+	var discount PriceType
+	for _, ar := range p.baseline.AppliedRules {
+		if !p.engine.traits[ar.Index].postOffer {
+			discount += ar.Discount
+		}
+	}
+
+	return discount
+}
+
+// Returns the cheapest of the given products, or of the catalogue if none are
+// given, which the cart's relations allow adding. Ties are broken by product
+// code.
+func (p *upsellProbe) cheapest(prodCodes []string) (Product, bool) {
+	if len(prodCodes) == 0 {
+		for code := range p.cart.catalogue {
+			prodCodes = append(prodCodes, code)
+		}
+	}
+
+	var cheapest Product
+	found := false
+	for _, code := range prodCodes {
+		product, ok := p.cart.catalogue[code]
+		if !ok || product.Price <= 0 || p.cart.checkRelations(code) != nil {
+			continue
+		}
+
+		if !found || product.Price < cheapest.Price || (product.Price == cheapest.Price && product.Code < cheapest.Code) {
+			cheapest, found = product, true
+		}
+	}
+
+	return cheapest, found
+}
+
+// Returns whether n units of a product could be added to the cart, as
+// AddLine would allow.
+func (c *defaultCart) canAdd(prodCode string, n int) bool {
+	return n <= c.Allowance(prodCode) && c.checkRelations(prodCode) == nil
+}
+
+// Returns the promo codes the rules declare they depend on, sorted.
+func promoCodesOf(rules []Rule) []string {
+	var codes []string
+	for _, rule := range rules {
+		walkRule(rule, func(r Rule) bool {
+			if d, ok := r.(Dependent); ok {
+				codes = append(codes, d.Dependencies().PromoCodes...)
+			}
+			return false
+		})
+	}
+
+	return sortedCodes(codes)
+}
+
+// Returns the MinSpend conditions a rule requires, Ie. those of its condition
+// or combined with And.
+func minSpendConditionsOf(rule Rule) []*minSpendCondition {
+	var conditions []*minSpendCondition
+	walkRule(rule, func(r Rule) bool {
+		if cr, ok := r.(*conditionalRule); ok {
+			conditions = requiredMinSpends(cr.condition, conditions)
+		}
+		return false
+	})
+
+	return conditions
+}
+
+func requiredMinSpends(cond Condition, conditions []*minSpendCondition) []*minSpendCondition {
+	switch c := cond.(type) {
+	case *minSpendCondition:
+		conditions = append(conditions, c)
+	case *andCondition:
+		for _, cond := range c.conditions {
+			conditions = requiredMinSpends(cond, conditions)
+		}
+	}
+
+	return conditions
+}
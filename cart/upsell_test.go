@@ -0,0 +1,114 @@
+package cart
+
+import (
+	"testing"
+)
+
+func findHint(hints []UpsellHint, kind UpsellKind, code string) (UpsellHint, bool) {
+	for _, h := range hints {
+		if h.Kind == kind && (h.ProdCode == code || h.PromoCode == code) {
+			return h, true
+		}
+	}
+
+	return UpsellHint{}, false
+}
+
+func Test_UpsellHints_WHEN_OneUnitFromXForY_EXPECT_AddOneHint(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(CreateDefaultRules(), catalogue)
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+
+	hints := c.UpsellHints()
+
+	h, ok := findHint(hints, AddProductHint, "ult_small")
+	if !ok || h.Count != 1 || h.Saving != 2490 || len(h.Rules) != 1 || h.Rules[0].Index != 0 {
+		t.Errorf("Hints=%v", hints)
+	}
+
+	// A promo code discounts the whole cart.
+	if h, ok := findHint(hints, EnterPromoCodeHint, "I<3AMAYSIM"); !ok || h.Saving != 498 {
+		t.Errorf("Hints=%v", hints)
+	}
+
+	if hints[0].Kind != AddProductHint || hints[0].ProdCode != "ult_small" {
+		t.Errorf("Hints=%v, Expected largest saving first", hints)
+	}
+
+	// The cart is unchanged by probing.
+	if c.Total() != 2*2490 || len(c.Items()) != 1 || len(c.PromoCodes()) != 0 {
+		t.Errorf("CartTotal=%d Items=%v PromoCodes=%v", c.Total(), c.Items(), c.PromoCodes())
+	}
+}
+
+func Test_UpsellHints_WHEN_OfferAlreadyApplies_EXPECT_NoHintForIt(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(CreateDefaultRules(), catalogue)
+	c.Add(catalogue["ult_medium"])
+	c.AddPromoCode("I<3AMAYSIM")
+
+	hints := c.UpsellHints()
+
+	// Another ult_medium only adds to the bundle which already applies.
+	for _, code := range []string{"ult_medium", "I<3AMAYSIM"} {
+		if _, ok := findHint(hints, AddProductHint, code); ok {
+			t.Errorf("Hints=%v", hints)
+		}
+		if _, ok := findHint(hints, EnterPromoCodeHint, code); ok {
+			t.Errorf("Hints=%v", hints)
+		}
+	}
+}
+
+func Test_UpsellHints_WHEN_BelowSpendThreshold_EXPECT_SpendMoreHint(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart([]Rule{CreateSpendThresholdRule(10000, UndiscountedSpend, FixedDiscount(1000))}, catalogue)
+	for i := 0; i < 3; i++ {
+		c.Add(catalogue["ult_small"])
+	}
+
+	h, ok := findHint(c.UpsellHints(), SpendMoreHint, "1gb")
+	if !ok || h.Amount != 10000-3*2490 || h.Count != 3 || h.Saving != 1000 {
+		t.Errorf("Hint=%v %+v", ok, h)
+	}
+}
+
+func Test_UpsellHints_WHEN_ConstraintReached_EXPECT_NoAddHint(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	c := CreateCart(CreateDefaultRules(), catalogue, WithConstraints(Constraints{MaxUnitsPerProduct: map[string]int{"ult_small": 2}}))
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+
+	if h, ok := findHint(c.UpsellHints(), AddProductHint, "ult_small"); ok {
+		t.Errorf("Hint=%v", h)
+	}
+}
+
+func Test_UpsellHints_WHEN_ProductExcluded_EXPECT_NoHintForIt(t *testing.T) {
+	catalogue := CreateDefaultCatalogue()
+	rules := append(CreateDefaultRules(), CreateSpendThresholdRule(10000, UndiscountedSpend, FixedDiscount(1000)))
+	relations := Relations{
+		{Excludes, "ult_large", []string{"ult_small"}},
+		{Excludes, "1gb", []string{"ult_small"}},
+	}
+	c := CreateCart(rules, catalogue, WithRelations(relations))
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+	c.Add(catalogue["ult_small"])
+
+	hints := c.UpsellHints()
+
+	if h, ok := findHint(hints, AddProductHint, "ult_large"); ok {
+		t.Errorf("Hint=%v", h)
+	}
+
+	// The spend is made up with the cheapest product which can be added.
+	if _, ok := findHint(hints, SpendMoreHint, "1gb"); ok {
+		t.Errorf("Hints=%v", hints)
+	}
+
+	if h, ok := findHint(hints, SpendMoreHint, "ult_small"); !ok || h.Count != 2 {
+		t.Errorf("Hints=%v", hints)
+	}
+}